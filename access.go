@@ -0,0 +1,166 @@
+package pn532
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+const defaultRelayPulseMs = 500
+
+// Access reasons reported in access_reason.
+const (
+	accessReasonDenyUIDs   = "deny_uids"
+	accessReasonAllowUIDs  = "allow_uids"
+	accessReasonNotAllowed = "not_allowed"
+	accessReasonNotDenied  = "not_denied"
+)
+
+// accessPolicy evaluates a UID against the allow/deny config. Deny rules win
+// over allow rules; with no allow rules, any UID that is not denied is
+// granted.
+type accessPolicy struct {
+	allowUIDs   map[string]bool
+	denyUIDs    map[string]bool
+	groups      map[string]map[string]bool
+	allowGroups []string
+	denyGroups  []string
+}
+
+// newAccessPolicy returns nil if cfg configures no access control.
+func newAccessPolicy(cfg *Config) *accessPolicy {
+	if !hasAccessLists(cfg) {
+		return nil
+	}
+
+	p := &accessPolicy{
+		allowUIDs:   uidSet(cfg.AllowUIDs),
+		denyUIDs:    uidSet(cfg.DenyUIDs),
+		groups:      make(map[string]map[string]bool, len(cfg.Groups)),
+		allowGroups: cfg.AllowGroups,
+		denyGroups:  cfg.DenyGroups,
+	}
+	for name, uids := range cfg.Groups {
+		p.groups[name] = uidSet(uids)
+	}
+	return p
+}
+
+// hasAccessLists reports whether cfg sets any allow or deny list, without
+// which no detection is granted.
+func hasAccessLists(cfg *Config) bool {
+	return len(cfg.AllowUIDs) > 0 || len(cfg.DenyUIDs) > 0 || len(cfg.AllowGroups) > 0 || len(cfg.DenyGroups) > 0
+}
+
+func uidSet(uids []string) map[string]bool {
+	set := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		set[strings.ToLower(uid)] = true
+	}
+	return set
+}
+
+// evaluate returns whether uid is granted access and why. Group reasons are
+// "deny_group:<name>" and "allow_group:<name>".
+func (p *accessPolicy) evaluate(uid string) (bool, string) {
+	uid = strings.ToLower(uid)
+
+	if p.denyUIDs[uid] {
+		return false, accessReasonDenyUIDs
+	}
+	for _, g := range p.denyGroups {
+		if p.groups[g][uid] {
+			return false, "deny_group:" + g
+		}
+	}
+	if p.allowUIDs[uid] {
+		return true, accessReasonAllowUIDs
+	}
+	for _, g := range p.allowGroups {
+		if p.groups[g][uid] {
+			return true, "allow_group:" + g
+		}
+	}
+	if len(p.allowUIDs) > 0 || len(p.allowGroups) > 0 {
+		return false, accessReasonNotAllowed
+	}
+	return true, accessReasonNotDenied
+}
+
+// doorRelay pulses a board GPIO pin high for a fixed duration on each
+// granted detection. A detection during a pulse does not extend it.
+type doorRelay struct {
+	board  board.Board
+	pin    board.GPIOPin
+	pulse  time.Duration
+	logger logging.Logger
+
+	active atomic.Bool
+	wg     sync.WaitGroup
+}
+
+// newDoorRelay resolves the relay pin from the configured board dependency.
+// It returns nil if no relay is configured.
+func newDoorRelay(deps resource.Dependencies, cfg *Config, logger logging.Logger) (*doorRelay, error) {
+	if cfg.RelayPin == "" {
+		return nil, nil
+	}
+	b, err := board.FromDependencies(deps, cfg.Board)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board %q: %w", cfg.Board, err)
+	}
+	pin, err := b.GPIOPinByName(cfg.RelayPin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relay pin %q: %w", cfg.RelayPin, err)
+	}
+	pulseMs := cfg.RelayPulseMs
+	if pulseMs <= 0 {
+		pulseMs = defaultRelayPulseMs
+	}
+	return &doorRelay{board: b, pin: pin, pulse: time.Duration(pulseMs) * time.Millisecond, logger: logger}, nil
+}
+
+// trigger starts a pulse in the background unless one is already running.
+// ctx cancellation cuts the pulse short; the pin is always driven low again.
+func (r *doorRelay) trigger(ctx context.Context) {
+	if !r.active.CompareAndSwap(false, true) {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.active.Store(false)
+
+		if err := r.pin.Set(ctx, true, nil); err != nil {
+			r.logger.Errorw("failed to energize door relay", "error", err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.pulse):
+		}
+
+		// Use a fresh context: the relay must be released even when the
+		// sensor is closing.
+		offCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := r.pin.Set(offCtx, false, nil); err != nil {
+			r.logger.Errorw("failed to release door relay", "error", err)
+		}
+	}()
+}
+
+// wait blocks until any running pulse has finished.
+func (r *doorRelay) wait() {
+	if r != nil {
+		r.wg.Wait()
+	}
+}
@@ -0,0 +1,102 @@
+package pn532
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+)
+
+// fakePin records the levels a GPIO pin is set to.
+type fakePin struct {
+	board.GPIOPin
+
+	mu     sync.Mutex
+	states []bool
+}
+
+func (p *fakePin) Set(_ context.Context, high bool, _ map[string]interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states = append(p.states, high)
+	return nil
+}
+
+func TestAccessPolicyEvaluate(t *testing.T) {
+	p := newAccessPolicy(&Config{
+		AllowUIDs:   []string{"04AAAAAA"},
+		DenyUIDs:    []string{"04dddddd"},
+		Groups:      map[string][]string{"staff": {"04bbbbbb", "04dddddd"}, "revoked": {"04cccccc"}},
+		AllowGroups: []string{"staff"},
+		DenyGroups:  []string{"revoked"},
+	})
+
+	tests := []struct {
+		uid     string
+		granted bool
+		reason  string
+	}{
+		{"04aaaaaa", true, accessReasonAllowUIDs},
+		{"04bbbbbb", true, "allow_group:staff"},
+		{"04cccccc", false, "deny_group:revoked"},
+		{"04dddddd", false, accessReasonDenyUIDs},
+		{"04eeeeee", false, accessReasonNotAllowed},
+	}
+	for _, tt := range tests {
+		granted, reason := p.evaluate(tt.uid)
+		if granted != tt.granted || reason != tt.reason {
+			t.Errorf("evaluate(%s) = %v, %q; want %v, %q", tt.uid, granted, reason, tt.granted, tt.reason)
+		}
+	}
+}
+
+func TestAccessPolicyDenyOnly(t *testing.T) {
+	if newAccessPolicy(&Config{}) != nil {
+		t.Fatal("no access config should yield a nil policy")
+	}
+
+	p := newAccessPolicy(&Config{DenyUIDs: []string{"04dddddd"}})
+	if granted, reason := p.evaluate("04aaaaaa"); !granted || reason != accessReasonNotDenied {
+		t.Errorf("evaluate = %v, %q; want granted, %q", granted, reason, accessReasonNotDenied)
+	}
+}
+
+func TestDoorRelayPulse(t *testing.T) {
+	pin := &fakePin{}
+	r := &doorRelay{pin: pin, pulse: 20 * time.Millisecond, logger: logging.NewTestLogger(t)}
+	r.trigger(context.Background())
+	// A second grant during the pulse is ignored.
+	r.trigger(context.Background())
+	r.wait()
+
+	pin.mu.Lock()
+	defer pin.mu.Unlock()
+	if len(pin.states) != 2 || !pin.states[0] || pin.states[1] {
+		t.Errorf("pin states = %v, want [true false]", pin.states)
+	}
+}
+
+func TestOnCardDetectedReportsAccess(t *testing.T) {
+	readNDEF := false
+	cfg := &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+		DenyUIDs:   []string{"0412345678"},
+	}
+	s, mock := newTestSensorWithDevice(t, cfg)
+	s.access = newAccessPolicy(cfg)
+
+	tag := setupNTAG215Mock(mock)
+	if err := s.onCardDetected(context.Background(), tag); err != nil {
+		t.Fatalf("onCardDetected: %v", err)
+	}
+
+	readings := buildReadingsFromState(&s.state)
+	if readings["access_granted"] != false || readings["access_reason"] != accessReasonDenyUIDs {
+		t.Errorf("access = %v, %v; want false, %s", readings["access_granted"], readings["access_reason"], accessReasonDenyUIDs)
+	}
+}
@@ -0,0 +1,164 @@
+package pn532
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+	"go.viam.com/rdk/logging"
+)
+
+const transportAuto = "auto"
+
+// autoProbeTimeout bounds the GetFirmwareVersion handshake with each
+// candidate, so a port with nothing (or something else) attached is skipped
+// quickly.
+const autoProbeTimeout = time.Second
+
+// autoDetectPaths lists, in probe order, the device paths tried for each
+// transport when transport is "auto". I2C comes first because an empty bus
+// fails fastest; the Raspberry Pi UARTs come before USB serial adapters.
+var autoDetectPaths = []struct {
+	transport string
+	patterns  []string
+}{
+	{"i2c", []string{"/dev/i2c-*"}},
+	{"spi", []string{"/dev/spidev*"}},
+	{"uart", []string{
+		"/dev/serial0", "/dev/ttyAMA*", "/dev/ttyS0",
+		"/dev/ttyUSB*", "/dev/ttyACM*",
+		"/dev/tty.usbserial*", "/dev/tty.wchusbserial*", "/dev/tty.SLAB_USBtoUART*",
+	}},
+}
+
+// transportCandidate is a transport and device path that may have a PN532
+// behind it.
+type transportCandidate struct {
+	transport string
+	path      string
+}
+
+// autoDetectCandidates expands autoDetectPaths with glob, dropping paths that
+// resolve to a device already listed (/dev/serial0 is a link to a ttyAMA or
+// ttyS port). If devicePath is set, only it is tried, with every transport.
+func autoDetectCandidates(devicePath string, glob func(string) ([]string, error)) []transportCandidate {
+	var candidates []transportCandidate
+	seen := make(map[string]bool)
+	for _, group := range autoDetectPaths {
+		if devicePath != "" {
+			candidates = append(candidates, transportCandidate{group.transport, devicePath})
+			continue
+		}
+		for _, pattern := range group.patterns {
+			paths, err := glob(pattern)
+			if err != nil {
+				continue
+			}
+			for _, path := range paths {
+				target := canonicalDevicePath(path)
+				if seen[target] {
+					continue
+				}
+				seen[target] = true
+				candidates = append(candidates, transportCandidate{group.transport, path})
+			}
+		}
+	}
+	return candidates
+}
+
+// probeFunc checks for a PN532 at a candidate and returns its firmware
+// version.
+type probeFunc func(ctx context.Context, c transportCandidate) (*pn532.FirmwareVersion, error)
+
+// probePN532 opens c and asks for the firmware version. Only a PN532 answers
+// with a valid frame, which a raw I2C address scan would not tell apart from
+// any other chip at 0x24.
+func probePN532(ctx context.Context, c transportCandidate) (*pn532.FirmwareVersion, error) {
+	transport, err := transportFactory(c.transport)(c.path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = transport.Close() }()
+
+	device, err := pn532.New(transport)
+	if err != nil {
+		return nil, err
+	}
+	if err := device.SetTimeout(autoProbeTimeout); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, autoProbeTimeout)
+	defer cancel()
+	return device.GetFirmwareVersion(ctx)
+}
+
+// detectTransport returns the first candidate that probe accepts. Paths held
+// open by a running sensor are skipped: probing would interleave frames with
+// its polling.
+func detectTransport(ctx context.Context, candidates []transportCandidate, probe probeFunc, logger logging.Logger) (transportCandidate, error) {
+	for _, c := range candidates {
+		if err := ctx.Err(); err != nil {
+			return transportCandidate{}, err
+		}
+		if openDevices.holds(c.path) {
+			logger.Debugw("skipping device in use", "device_path", c.path)
+			continue
+		}
+		if _, err := probe(ctx, c); err != nil {
+			logger.Debugw("no PN532 found", "transport", c.transport, "device_path", c.path, "error", err)
+			continue
+		}
+		logger.Infow("PN532 auto-detected", "transport", c.transport, "device_path", c.path)
+		return c, nil
+	}
+	return transportCandidate{}, fmt.Errorf("transport auto: no PN532 answered on %d candidate device(s)", len(candidates))
+}
+
+// openDevices tracks the device paths sensors in this process have open.
+var openDevices = &deviceClaims{paths: make(map[string]int)}
+
+// deviceClaims counts claims on device paths, keyed by the path with links
+// resolved so /dev/serial0 and /dev/ttyAMA0 are the same device. Claims on
+// an empty path are ignored.
+type deviceClaims struct {
+	mu    sync.Mutex
+	paths map[string]int
+}
+
+func canonicalDevicePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+func (d *deviceClaims) claim(path string) {
+	if path == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paths[canonicalDevicePath(path)]++
+}
+
+func (d *deviceClaims) release(path string) {
+	if path == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := canonicalDevicePath(path)
+	if d.paths[key]--; d.paths[key] <= 0 {
+		delete(d.paths, key)
+	}
+}
+
+func (d *deviceClaims) holds(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paths[canonicalDevicePath(path)] > 0
+}
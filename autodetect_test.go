@@ -0,0 +1,92 @@
+package pn532
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"go.viam.com/rdk/logging"
+)
+
+func TestAutoDetectCandidates(t *testing.T) {
+	dir := t.TempDir()
+	ttyAMA0 := filepath.Join(dir, "ttyAMA0")
+	serial0 := filepath.Join(dir, "serial0")
+	if err := os.WriteFile(ttyAMA0, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(ttyAMA0, serial0); err != nil {
+		t.Fatal(err)
+	}
+
+	devices := map[string][]string{
+		"/dev/i2c-*":   {"/dev/i2c-1", "/dev/i2c-20"},
+		"/dev/spidev*": {"/dev/spidev0.0"},
+		"/dev/serial0": {serial0},
+		"/dev/ttyAMA*": {ttyAMA0},
+		"/dev/ttyUSB*": {"/dev/ttyUSB0"},
+		"/dev/ttyACM*": nil,
+		"/dev/ttyS0":   nil,
+	}
+	glob := func(pattern string) ([]string, error) { return devices[pattern], nil }
+
+	want := []transportCandidate{
+		{"i2c", "/dev/i2c-1"},
+		{"i2c", "/dev/i2c-20"},
+		{"spi", "/dev/spidev0.0"},
+		{"uart", serial0},
+		{"uart", "/dev/ttyUSB0"},
+	}
+	if got := autoDetectCandidates("", glob); !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates = %v, want %v", got, want)
+	}
+
+	want = []transportCandidate{{"i2c", "/dev/ttyUSB0"}, {"spi", "/dev/ttyUSB0"}, {"uart", "/dev/ttyUSB0"}}
+	if got := autoDetectCandidates("/dev/ttyUSB0", glob); !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates with device_path = %v, want %v", got, want)
+	}
+}
+
+func TestDetectTransport(t *testing.T) {
+	candidates := []transportCandidate{{"i2c", "/dev/i2c-1"}, {"spi", "/dev/spidev0.0"}, {"uart", "/dev/ttyUSB0"}}
+	var probed []string
+	probe := func(_ context.Context, c transportCandidate) (*pn532lib.FirmwareVersion, error) {
+		probed = append(probed, c.path)
+		if c.transport == "spi" {
+			return &pn532lib.FirmwareVersion{Version: "1.6"}, nil
+		}
+		return nil, errors.New("timeout")
+	}
+
+	got, err := detectTransport(context.Background(), candidates, probe, logging.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("detectTransport: %v", err)
+	}
+	if got != candidates[1] {
+		t.Errorf("detected %v, want %v", got, candidates[1])
+	}
+	if len(probed) != 2 {
+		t.Errorf("probed %v, want to stop at the first PN532", probed)
+	}
+
+	none := func(context.Context, transportCandidate) (*pn532lib.FirmwareVersion, error) {
+		return nil, errors.New("timeout")
+	}
+	if _, err := detectTransport(context.Background(), candidates, none, logging.NewTestLogger(t)); err == nil || !strings.Contains(err.Error(), "3 candidate") {
+		t.Errorf("expected no PN532 error, got %v", err)
+	}
+}
+
+func TestSetTagKeepsEndpoint(t *testing.T) {
+	st := tagState{deviceHealthy: true, transport: "uart", devicePath: "/dev/serial0"}
+	st.setTag(tagState{tagPresent: true, uid: "04aabbcc"})
+	st.clearTag()
+	if st.transport != "uart" || st.devicePath != "/dev/serial0" {
+		t.Errorf("transport, devicePath = %q, %q after clearTag", st.transport, st.devicePath)
+	}
+}
@@ -0,0 +1,404 @@
+package pn532
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+	"go.viam.com/rdk/logging"
+)
+
+// Poll modes for poll_mode.
+const (
+	pollModeSession  = "session"
+	pollModeAutopoll = "autopoll"
+)
+
+var validPollModes = []string{pollModeSession, pollModeAutopoll}
+
+const (
+	defaultAutopollPeriodMs = 300
+	defaultAutopollCount    = 1
+
+	// autopollPeriodUnit is the unit of the InAutoPoll Period parameter.
+	autopollPeriodUnit = 150 * time.Millisecond
+	// The PN532 accepts Period values 1–15 and PollNr 0x01–0xFE (0xFF polls
+	// forever, which would hold the device indefinitely).
+	minAutopollPeriodMs = 150
+	maxAutopollPeriodMs = 15 * 150
+	maxAutopollCount    = 0xFE
+)
+
+// Tag types reported for targets that only InAutoPoll discovers. Tag
+// operations are not available for them.
+const (
+	tagTypeISO14443B pn532.TagType = "ISO14443B"
+	tagTypeJewel     pn532.TagType = "JEWEL"
+)
+
+// autopollTargetTypes maps autopoll_targets names to InAutoPoll target types.
+var autopollTargetTypes = map[string]pn532.AutoPollTarget{
+	"iso14443a": pn532.AutoPollGeneric106kbps,
+	"felica212": pn532.AutoPollFeliCa212,
+	"felica424": pn532.AutoPollFeliCa424,
+	"iso14443b": pn532.AutoPollISO14443B,
+	"jewel":     pn532.AutoPollJewel,
+}
+
+var defaultAutopollTargets = []string{"iso14443a", "felica212", "felica424", "iso14443b", "jewel"}
+
+// tagPoller drives tag detection on one connected device. *polling.Session
+// implements it for poll_mode "session" and *autoPoller for "autopoll".
+type tagPoller interface {
+	Start(ctx context.Context) error
+	PauseAndRun(ctx context.Context, fn func(device *pn532.Device) error) error
+	WriteToNextTag(sessionCtx, writeCtx context.Context, timeout time.Duration, writeFn func(context.Context, pn532.Tag) error) error
+	Close() error
+}
+
+// autoPoller detects tags with the PN532's InAutoPoll command, which cycles
+// through the configured target types on the chip itself. Unlike the polling
+// session it also finds FeliCa, ISO14443B, and Jewel targets. Detections and
+// removals are reported through the same callbacks as the session.
+type autoPoller struct {
+	device         *pn532.Device
+	targets        []pn532.AutoPollTarget
+	count          byte
+	period         byte
+	interval       time.Duration
+	removalTimeout time.Duration
+	// deviceTimeout is the device's operation timeout outside poll cycles;
+	// Start raises it to cycleTimeout while polling.
+	deviceTimeout time.Duration
+	logger        logging.Logger
+
+	onDetected     func(context.Context, *pn532.DetectedTag) error
+	onRemoved      func()
+	onDisconnected func(error)
+
+	// mu gives one caller at a time the device: the poll loop for a single
+	// cycle, or PauseAndRun and WriteToNextTag for their duration. It also
+	// guards polling and the presence fields below.
+	mu       sync.Mutex
+	polling  bool
+	present  bool
+	uid      string
+	lastSeen time.Time
+}
+
+// newAutoPoller builds an autoPoller for device from cfg, which must have
+// been validated and had its defaults applied.
+func newAutoPoller(device *pn532.Device, cfg *Config, logger logging.Logger) *autoPoller {
+	names := cfg.AutopollTargets
+	if len(names) == 0 {
+		names = defaultAutopollTargets
+	}
+	targets := make([]pn532.AutoPollTarget, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, autopollTargetTypes[name])
+	}
+
+	periodMs := cfg.AutopollPeriodMs
+	if periodMs <= 0 {
+		periodMs = defaultAutopollPeriodMs
+	}
+	count := cfg.AutopollCount
+	if count <= 0 {
+		count = defaultAutopollCount
+	}
+
+	return &autoPoller{
+		device:         device,
+		targets:        targets,
+		count:          byte(count),
+		period:         byte(min(max(periodMs/int(autopollPeriodUnit/time.Millisecond), 1), 15)),
+		interval:       time.Duration(cfg.PollIntervalMs) * time.Millisecond,
+		removalTimeout: time.Duration(cfg.CardRemovalTimeoutMs) * time.Millisecond,
+		deviceTimeout:  deviceTimeout(cfg),
+		logger:         logger,
+	}
+}
+
+func (p *autoPoller) SetOnCardDetected(callback func(context.Context, *pn532.DetectedTag) error) {
+	p.onDetected = callback
+}
+
+func (p *autoPoller) SetOnCardRemoved(callback func()) {
+	p.onRemoved = callback
+}
+
+func (p *autoPoller) SetOnDeviceDisconnected(callback func(error)) {
+	p.onDisconnected = callback
+}
+
+// cycleTimeout is the longest a single InAutoPoll can take with an empty
+// field, plus slack for the host and transport.
+func (p *autoPoller) cycleTimeout() time.Duration {
+	return time.Duration(p.count)*time.Duration(p.period)*time.Duration(len(p.targets))*autopollPeriodUnit + time.Second
+}
+
+// Start runs InAutoPoll cycles every poll interval until ctx is done or the
+// device reports a fatal error. The device timeout is raised to cover a poll
+// cycle while Start runs and restored when it returns.
+func (p *autoPoller) Start(ctx context.Context) error {
+	p.mu.Lock()
+	err := p.device.SetTimeout(p.cycleTimeout())
+	p.polling = err == nil
+	p.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to set device timeout: %w", err)
+	}
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.polling = false
+		p.restoreDeviceTimeout()
+	}()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.pollOnce(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollOnce runs one InAutoPoll cycle and reports any change in the field.
+// A tag counts as removed once it has been missing for removalTimeout.
+func (p *autoPoller) pollOnce(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tag, err := p.poll(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if pn532.IsFatal(err) {
+			if p.onDisconnected != nil {
+				p.onDisconnected(err)
+			}
+			return err
+		}
+		// Transient errors are treated as an empty field.
+		p.logger.Debugw("InAutoPoll failed", "error", err)
+	}
+
+	if tag == nil {
+		if p.present && time.Since(p.lastSeen) >= p.removalTimeout {
+			p.setAbsent()
+		}
+		return nil
+	}
+
+	p.lastSeen = time.Now()
+	if p.present && p.uid == tag.UID {
+		return nil
+	}
+	if p.present {
+		// A different tag replaced the previous one between cycles.
+		p.setAbsent()
+	}
+	p.present = true
+	p.uid = tag.UID
+	if p.onDetected != nil {
+		if err := p.onDetected(ctx, tag); err != nil {
+			p.logger.Warnw("card detected callback failed", "uid", tag.UID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (p *autoPoller) setAbsent() {
+	p.present = false
+	p.uid = ""
+	if p.onRemoved != nil {
+		p.onRemoved()
+	}
+}
+
+// poll runs a single InAutoPoll and returns the first target found, or nil
+// if the field is empty. The caller must hold p.mu.
+func (p *autoPoller) poll(ctx context.Context) (*pn532.DetectedTag, error) {
+	if p.present {
+		// The target activated by the previous cycle is released so the
+		// next poll selects it afresh.
+		if err := p.device.InRelease(ctx); err != nil {
+			p.logger.Debugw("InRelease before InAutoPoll failed", "error", err)
+		}
+	}
+
+	pollCtx, cancel := context.WithTimeout(ctx, p.cycleTimeout())
+	defer cancel()
+	res, err := p.device.InAutoPoll(pollCtx, p.count, p.period, p.targets)
+	if err != nil || res == nil {
+		return nil, err
+	}
+	return detectedTagFromAutoPoll(res), nil
+}
+
+func (p *autoPoller) restoreDeviceTimeout() {
+	if err := p.device.SetTimeout(p.deviceTimeout); err != nil {
+		p.logger.Debugw("failed to restore device timeout", "error", err)
+	}
+}
+
+// withDeviceTimeout runs fn with the device timeout in place of the poll
+// cycle timeout, so commands fn sends do not wait as long as InAutoPoll. The
+// caller must hold p.mu.
+func (p *autoPoller) withDeviceTimeout(fn func() error) error {
+	if !p.polling {
+		return fn()
+	}
+	p.restoreDeviceTimeout()
+	defer func() {
+		if err := p.device.SetTimeout(p.cycleTimeout()); err != nil {
+			p.logger.Debugw("failed to set device timeout", "error", err)
+		}
+	}()
+	return fn()
+}
+
+// PauseAndRun runs fn with exclusive use of the device between poll cycles.
+func (p *autoPoller) PauseAndRun(ctx context.Context, fn func(device *pn532.Device) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.withDeviceTimeout(func() error { return fn(p.device) })
+}
+
+// WriteToNextTag polls until a tag is in the field or timeout elapses, then
+// calls writeFn with it. Polling is suspended for the duration.
+func (p *autoPoller) WriteToNextTag(sessionCtx, writeCtx context.Context, timeout time.Duration, writeFn func(context.Context, pn532.Tag) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	timeoutCtx, cancel := context.WithTimeout(sessionCtx, timeout)
+	defer cancel()
+
+	for {
+		detected, err := p.poll(timeoutCtx)
+		if err != nil && timeoutCtx.Err() == nil {
+			return fmt.Errorf("tag detection failed: %w", err)
+		}
+		if detected != nil {
+			tag, err := p.device.CreateTag(detected)
+			if err != nil {
+				return fmt.Errorf("failed to create tag: %w", err)
+			}
+			return p.withDeviceTimeout(func() error {
+				if err := writeFn(writeCtx, tag); err != nil {
+					_ = p.device.ClearTransportState()
+					return err
+				}
+				return nil
+			})
+		}
+
+		select {
+		case <-timeoutCtx.Done():
+			if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+				return errors.New("timeout waiting for tag")
+			}
+			return timeoutCtx.Err()
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+// Close is a no-op; the poll loop stops when its context is cancelled.
+func (p *autoPoller) Close() error {
+	return nil
+}
+
+// detectedTagFromAutoPoll decodes an InAutoPoll target. TargetData starts with
+// the logical target number, followed by the same fields InListPassiveTarget
+// returns for the target's modulation.
+func detectedTagFromAutoPoll(res *pn532.AutoPollResult) *pn532.DetectedTag {
+	tag := &pn532.DetectedTag{
+		DetectedAt: time.Now(),
+		Type:       pn532.TagTypeUnknown,
+	}
+
+	var data []byte
+	if len(res.TargetData) > 0 {
+		data = res.TargetData[1:]
+	}
+
+	switch res.Type {
+	case pn532.AutoPollGeneric106kbps, pn532.AutoPollMifare, pn532.AutoPollISO14443A:
+		// SENS_RES(2) SEL_RES(1) NFCIDLength(1) NFCID1 [ATS]
+		if len(data) >= 4 && len(data) >= 4+int(data[3]) {
+			tag.ATQ = data[0:2]
+			tag.SAK = data[2]
+			tag.UIDBytes = data[4 : 4+int(data[3])]
+			tag.Type = typeAFromSAK(tag.SAK)
+		}
+	case pn532.AutoPollGeneric212kbps, pn532.AutoPollGeneric424kbps, pn532.AutoPollFeliCa212, pn532.AutoPollFeliCa424:
+		// POL_RES length(1) 0x01 IDm(8) PMm(8) [SYST_CODE(2)]
+		if len(data) >= 18 {
+			end := 18
+			if len(data) >= 20 {
+				end = 20
+			}
+			tag.UIDBytes = data[2:10]
+			tag.TargetData = data[1:end]
+			tag.Type = pn532.TagTypeFeliCa
+		}
+	case pn532.AutoPollISO14443B, pn532.AutoPollISO14443B4:
+		// ATQB(12) ATTRIB_RES length(1) ATTRIB_RES; the PUPI follows the
+		// 0x50 ATQB header.
+		if len(data) >= 5 {
+			tag.UIDBytes = data[1:5]
+			tag.Type = tagTypeISO14443B
+		}
+	case pn532.AutoPollJewel:
+		// SENS_RES(2) JEWELID(4)
+		if len(data) >= 6 {
+			tag.UIDBytes = data[2:6]
+			tag.Type = tagTypeJewel
+		}
+	}
+
+	tag.UID = hex.EncodeToString(tag.UIDBytes)
+	return tag
+}
+
+// typeAFromSAK classifies an ISO14443A target by its SAK: MIFARE Classic
+// sets bit 3, Type 2 tags (NTAG, Ultralight) answer 0x00.
+func typeAFromSAK(sak byte) pn532.TagType {
+	switch {
+	case sak&0x08 != 0:
+		return pn532.TagTypeMIFARE
+	case sak == 0x00:
+		return pn532.TagTypeNTAG
+	default:
+		return pn532.TagTypeUnknown
+	}
+}
+
+// hasTagOps reports whether tagops supports tags of type t. FeliCa is read
+// directly (see felica.go). It only gates autopoll detections, which include
+// target types the polling session never reports.
+func hasTagOps(t pn532.TagType) bool {
+	switch t {
+	case pn532.TagTypeNTAG, pn532.TagTypeMIFARE:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,246 @@
+package pn532
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"go.viam.com/rdk/logging"
+)
+
+func TestDetectedTagFromAutoPoll(t *testing.T) {
+	felica := []byte{0x01, 0x14, 0x01,
+		0x01, 0x2E, 0x4C, 0x11, 0x22, 0x33, 0x44, 0x55, // IDm
+		0x03, 0x01, 0x4B, 0x02, 0x4F, 0x49, 0x93, 0xFF, // PMm
+		0x12, 0xFC, // system code
+	}
+	// A POL_RES without the optional system code.
+	felicaNoSystemCode := append([]byte{0x01, 0x12}, felica[2:19]...)
+
+	tests := []struct {
+		name    string
+		res     pn532lib.AutoPollResult
+		wantUID string
+		want    pn532lib.TagType
+	}{
+		{
+			name:    "ntag",
+			res:     pn532lib.AutoPollResult{Type: pn532lib.AutoPollGeneric106kbps, TargetData: []byte{0x01, 0x00, 0x44, 0x00, 0x07, 0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6}},
+			wantUID: "04a1b2c3d4e5f6",
+			want:    pn532lib.TagTypeNTAG,
+		},
+		{
+			name:    "mifare classic",
+			res:     pn532lib.AutoPollResult{Type: pn532lib.AutoPollMifare, TargetData: []byte{0x01, 0x00, 0x04, 0x08, 0x04, 0x63, 0xCF, 0x41, 0xE4}},
+			wantUID: "63cf41e4",
+			want:    pn532lib.TagTypeMIFARE,
+		},
+		{
+			name:    "felica",
+			res:     pn532lib.AutoPollResult{Type: pn532lib.AutoPollFeliCa212, TargetData: felica},
+			wantUID: "012e4c1122334455",
+			want:    pn532lib.TagTypeFeliCa,
+		},
+		{
+			name:    "felica without system code",
+			res:     pn532lib.AutoPollResult{Type: pn532lib.AutoPollFeliCa424, TargetData: felicaNoSystemCode},
+			wantUID: "012e4c1122334455",
+			want:    pn532lib.TagTypeFeliCa,
+		},
+		{
+			name: "felica truncated",
+			res:  pn532lib.AutoPollResult{Type: pn532lib.AutoPollFeliCa212, TargetData: felica[:18]},
+			want: pn532lib.TagTypeUnknown,
+		},
+		{
+			name:    "iso14443b",
+			res:     pn532lib.AutoPollResult{Type: pn532lib.AutoPollISO14443B, TargetData: []byte{0x01, 0x50, 0x92, 0x5A, 0x1C, 0x3E, 0x00, 0x00, 0x00, 0x00, 0x00, 0x71, 0x85, 0x01, 0x00}},
+			wantUID: "925a1c3e",
+			want:    tagTypeISO14443B,
+		},
+		{
+			name:    "jewel",
+			res:     pn532lib.AutoPollResult{Type: pn532lib.AutoPollJewel, TargetData: []byte{0x01, 0x0C, 0x00, 0xAA, 0xBB, 0xCC, 0xDD}},
+			wantUID: "aabbccdd",
+			want:    tagTypeJewel,
+		},
+		{
+			name: "truncated",
+			res:  pn532lib.AutoPollResult{Type: pn532lib.AutoPollGeneric106kbps, TargetData: []byte{0x01, 0x00, 0x44, 0x00, 0x07, 0x04}},
+			want: pn532lib.TagTypeUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := detectedTagFromAutoPoll(&tt.res)
+			if tag.UID != tt.wantUID {
+				t.Errorf("UID = %q, want %q", tag.UID, tt.wantUID)
+			}
+			if tag.Type != tt.want {
+				t.Errorf("Type = %q, want %q", tag.Type, tt.want)
+			}
+		})
+	}
+
+	// FeliCa target data is handed to tagops starting at the response code.
+	tag := detectedTagFromAutoPoll(&pn532lib.AutoPollResult{Type: pn532lib.AutoPollFeliCa212, TargetData: felica})
+	if len(tag.TargetData) == 0 || tag.TargetData[0] != 0x01 {
+		t.Errorf("FeliCa TargetData = %X, want POL_RES starting with 0x01", tag.TargetData)
+	}
+}
+
+func TestAutoPollerDetectAndRemove(t *testing.T) {
+	mock := pn532lib.NewMockTransport()
+	device, err := pn532lib.New(mock)
+	if err != nil {
+		t.Fatalf("failed to create mock device: %v", err)
+	}
+
+	cfg := applyConfigDefaults(&Config{Transport: "i2c", DevicePath: "/dev/i2c-1", PollMode: pollModeAutopoll})
+	p := newAutoPoller(device, cfg, logging.NewTestLogger(t))
+	p.removalTimeout = 0
+
+	var detected []string
+	removed := 0
+	p.SetOnCardDetected(func(_ context.Context, tag *pn532lib.DetectedTag) error {
+		detected = append(detected, tag.UID)
+		return nil
+	})
+	p.SetOnCardRemoved(func() { removed++ })
+
+	jewel := []byte{0x61, 0x01, byte(pn532lib.AutoPollJewel), 0x07, 0x01, 0x0C, 0x00, 0xAA, 0xBB, 0xCC, 0xDD}
+	felica := []byte{0x61, 0x01, byte(pn532lib.AutoPollFeliCa212), 0x15, 0x01, 0x14, 0x01,
+		0x01, 0x2E, 0x4C, 0x11, 0x22, 0x33, 0x44, 0x55,
+		0x03, 0x01, 0x4B, 0x02, 0x4F, 0x49, 0x93, 0xFF,
+		0x12, 0xFC,
+	}
+	mock.SetResponse(0x60, []byte{0x61, 0x00})
+	mock.QueueResponses(0x60, jewel, jewel, felica)
+
+	ctx := context.Background()
+	for range 4 {
+		if err := p.pollOnce(ctx); err != nil {
+			t.Fatalf("pollOnce returned error: %v", err)
+		}
+	}
+
+	if len(detected) != 2 || detected[0] != "aabbccdd" || detected[1] != "012e4c1122334455" {
+		t.Errorf("detected = %v, want [aabbccdd 012e4c1122334455]", detected)
+	}
+	// One removal when the FeliCa card replaced the Jewel tag, one when the
+	// field emptied.
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	if got := mock.GetCallCount(0x60); got != 4 {
+		t.Errorf("InAutoPoll calls = %d, want 4", got)
+	}
+}
+
+func TestAutoPollerPeriod(t *testing.T) {
+	device := newMockDevice(t)
+	cfg := applyConfigDefaults(&Config{Transport: "i2c", DevicePath: "/dev/i2c-1", PollMode: pollModeAutopoll, AutopollPeriodMs: 600, AutopollTargets: []string{"felica212"}})
+	p := newAutoPoller(device, cfg, logging.NewTestLogger(t))
+
+	if p.period != 4 {
+		t.Errorf("period = %d, want 4 (600ms in 150ms units)", p.period)
+	}
+	if len(p.targets) != 1 || p.targets[0] != pn532lib.AutoPollFeliCa212 {
+		t.Errorf("targets = %v, want [FeliCa212]", p.targets)
+	}
+	if p.count != defaultAutopollCount {
+		t.Errorf("count = %d, want %d", p.count, defaultAutopollCount)
+	}
+}
+
+// timeoutRecorder records the last timeout set on a MockTransport.
+type timeoutRecorder struct {
+	*pn532lib.MockTransport
+	mu      sync.Mutex
+	timeout time.Duration
+}
+
+func (r *timeoutRecorder) SetTimeout(timeout time.Duration) error {
+	r.mu.Lock()
+	r.timeout = timeout
+	r.mu.Unlock()
+	return r.MockTransport.SetTimeout(timeout)
+}
+
+func (r *timeoutRecorder) last() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timeout
+}
+
+func TestAutoPollerRestoresDeviceTimeout(t *testing.T) {
+	rec := &timeoutRecorder{MockTransport: pn532lib.NewMockTransport()}
+	device, err := pn532lib.New(rec)
+	if err != nil {
+		t.Fatalf("failed to create mock device: %v", err)
+	}
+	rec.SetResponse(0x60, []byte{0x61, 0x00})
+	cfg := applyConfigDefaults(&Config{Transport: "i2c", DevicePath: "/dev/i2c-1", PollMode: pollModeAutopoll})
+	p := newAutoPoller(device, cfg, logging.NewTestLogger(t))
+	want := time.Duration(defaultConnectTimeoutSec) * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Start(ctx) }()
+	if !waitFor(t, time.Second, func() bool { return rec.GetCallCount(0x60) > 0 }) {
+		t.Fatal("autopoll did not start")
+	}
+	if got := rec.last(); got != p.cycleTimeout() {
+		t.Errorf("timeout while polling = %v, want %v", got, p.cycleTimeout())
+	}
+
+	var during time.Duration
+	if err := p.PauseAndRun(ctx, func(*pn532lib.Device) error {
+		during = rec.last()
+		return nil
+	}); err != nil {
+		t.Fatalf("PauseAndRun: %v", err)
+	}
+	if during != want {
+		t.Errorf("timeout in PauseAndRun = %v, want %v", during, want)
+	}
+	if got := rec.last(); got != p.cycleTimeout() {
+		t.Errorf("timeout after PauseAndRun = %v, want %v", got, p.cycleTimeout())
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Start returned %v", err)
+	}
+	if got := rec.last(); got != want {
+		t.Errorf("timeout after Start returned = %v, want %v", got, want)
+	}
+}
+
+func TestOnCardDetectedTagOpsOnlyGatedInAutopoll(t *testing.T) {
+	// Session mode hands every tag to tagops, which probes an unknown tag as
+	// NTAG and MIFARE; in autopoll mode tag types without tagops support are
+	// not sent any reads.
+	for _, tt := range []struct {
+		mode      string
+		wantReads bool
+	}{
+		{pollModeSession, true},
+		{pollModeAutopoll, false},
+	} {
+		readNDEF := false
+		s, mock := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", PollMode: tt.mode, ReadNDEF: &readNDEF})
+		mock.SelectTarget()
+		tag := &pn532lib.DetectedTag{UID: "04aabbcc", UIDBytes: []byte{0x04, 0xAA, 0xBB, 0xCC}, Type: pn532lib.TagTypeUnknown}
+		if err := s.onCardDetected(context.Background(), tag); err != nil {
+			t.Fatalf("%s: onCardDetected returned error: %v", tt.mode, err)
+		}
+		if got := mock.GetCallCount(0x40) > 0; got != tt.wantReads {
+			t.Errorf("%s: tag read = %v, want %v", tt.mode, got, tt.wantReads)
+		}
+	}
+}
@@ -1,34 +1,326 @@
+// Command cli drives a PN532 reader directly, without viam-server, for
+// bench testing and debugging readers in the field.
 package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
 	"pn532"
+
+	sensor "go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
-	sensor "go.viam.com/rdk/components/sensor"
 )
 
+const usage = `Usage: pn532-cli [flags] <command> [args]
+
+Commands:
+  scan                   print each tag detection and removal until interrupted
+  read                   wait for a tag and print its readings
+  write-text <text>      write an NDEF text record to the next tag
+  write-ndef <records>   write NDEF records (JSON array as for write_ndef, or @file)
+  dump                   wait for a tag and dump its raw memory
+  diagnostics            run the reader self-test and print firmware details
+  watch                  print the sensor readings whenever they change
+
+Flags:
+`
+
+// options are the global flags shared by every command.
+type options struct {
+	transport string
+	device    string
+	json      bool
+	debug     bool
+	timeout   time.Duration
+	lang      string
+	save      bool
+	interval  time.Duration
+}
+
 func main() {
-	err := realMain()
-	if err != nil {
-		panic(err)
+	if err := realMain(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
 	}
 }
 
 func realMain() error {
-	ctx := context.Background()
-	logger := logging.NewLogger("cli")
+	var opts options
+	flags := flag.NewFlagSet("pn532-cli", flag.ContinueOnError)
+	flags.StringVar(&opts.transport, "transport", "auto", "transport: auto, uart, i2c or spi")
+	flags.StringVar(&opts.device, "device", "", "device path, e.g. /dev/ttyUSB0, /dev/i2c-1 or /dev/spidev0.0; optional with auto")
+	flags.BoolVar(&opts.json, "json", false, "print results as JSON, one object per line")
+	flags.BoolVar(&opts.debug, "debug", false, "log module and transport debug output")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "how long read, write-text, write-ndef and dump wait for a tag")
+	flags.StringVar(&opts.lang, "lang", "en", "language code for write-text")
+	flags.BoolVar(&opts.save, "save", false, "dump: also save the dump under dump_dir")
+	flags.DurationVar(&opts.interval, "interval", 250*time.Millisecond, "watch: how often to sample the readings")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no command given")
+	}
+
+	command, err := commandFor(args[0], args[1:], &opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := logging.NewLogger("pn532-cli")
+	if !opts.debug {
+		logger.SetLevel(logging.WARN)
+	}
+
+	cfg := pn532.Config{Transport: opts.transport, DevicePath: opts.device, Debug: opts.debug}
+	if _, _, err := cfg.Validate(""); err != nil {
+		return err
+	}
+
+	s, err := pn532.NewPn532(ctx, resource.Dependencies{}, sensor.Named("pn532-cli"), &cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer s.Close(context.Background())
+
+	err = command(ctx, s)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// commandFor returns the function running the named command against an open
+// sensor, rejecting missing or extra arguments before the reader is opened.
+func commandFor(name string, args []string, opts *options) (func(context.Context, sensor.Sensor) error, error) {
+	wantArgs := 0
+	if name == "write-text" || name == "write-ndef" {
+		wantArgs = 1
+	}
+	if len(args) != wantArgs {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", name, wantArgs, len(args))
+	}
+
+	switch name {
+	case "scan":
+		return opts.scan, nil
+	case "read":
+		return func(ctx context.Context, s sensor.Sensor) error {
+			opts.status("Present a tag...")
+			return opts.run(ctx, s, false, awaitTagCmd)
+		}, nil
+	case "write-text":
+		return func(ctx context.Context, s sensor.Sensor) error {
+			opts.status("Present a tag to write...")
+			return opts.run(ctx, s, false, map[string]interface{}{"action": "write_text", "text": args[0], "lang": opts.lang, "timeout_ms": opts.timeoutMs()})
+		}, nil
+	case "write-ndef":
+		records, err := parseRecords(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, s sensor.Sensor) error {
+			opts.status("Present a tag to write...")
+			return opts.run(ctx, s, false, map[string]interface{}{"action": "write_ndef", "records": records, "timeout_ms": opts.timeoutMs()})
+		}, nil
+	case "dump":
+		return func(ctx context.Context, s sensor.Sensor) error {
+			return opts.run(ctx, s, true, map[string]interface{}{"action": "dump_tag", "save": opts.save})
+		}, nil
+	case "diagnostics":
+		return func(ctx context.Context, s sensor.Sensor) error {
+			return opts.print(s.DoCommand(ctx, map[string]interface{}{"action": "diagnostics"}))
+		}, nil
+	case "watch":
+		return opts.watch, nil
+	default:
+		return nil, fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// parseRecords reads a write_ndef records array from arg, or from the file
+// it names if it starts with @.
+func parseRecords(arg string) ([]interface{}, error) {
+	data := []byte(arg)
+	if path, ok := strings.CutPrefix(arg, "@"); ok {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var records []interface{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("records must be a JSON array: %w", err)
+	}
+	return records, nil
+}
+
+// awaitTagCmd waits for a tag, including one already in the field when the
+// reader was opened: since 0 replays the detections from startup.
+var awaitTagCmd = map[string]interface{}{"action": "await_scan", "since": float64(0)}
+
+// run sends cmd within the --timeout deadline. With awaitTag it first waits
+// for a tag, for commands that need one in the field when they start.
+func (o *options) run(ctx context.Context, s sensor.Sensor, awaitTag bool, cmd map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	if awaitTag {
+		o.status("Present a tag...")
+		if _, err := s.DoCommand(ctx, awaitTagCmd); err != nil {
+			return err
+		}
+	}
+	return o.print(s.DoCommand(ctx, cmd))
+}
 
-	deps := resource.Dependencies{}
-	// can load these from a remote machine if you need
+// timeoutMs is --timeout as a DoCommand timeout_ms.
+func (o *options) timeoutMs() float64 {
+	return float64(o.timeout.Milliseconds())
+}
 
-	cfg := pn532.Config{Transport: "auto"}
+// scan prints detection and removal events as they happen.
+func (o *options) scan(ctx context.Context, s sensor.Sensor) error {
+	o.status("Scanning, press Ctrl-C to stop")
+	var since float64
+	for {
+		res, err := s.DoCommand(ctx, map[string]interface{}{"action": "get_events", "since": since, "wait_ms": float64(1000)})
+		if err != nil {
+			return err
+		}
+		events, _ := res["events"].([]interface{})
+		for _, e := range events {
+			event, _ := e.(map[string]interface{})
+			if seq, ok := toFloat(event["seq"]); ok {
+				since = seq
+			}
+			if err := o.printEvent(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watch prints the readings each time they differ from the last sample.
+func (o *options) watch(ctx context.Context, s sensor.Sensor) error {
+	o.status("Watching readings, press Ctrl-C to stop")
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	var last map[string]interface{}
+	for {
+		readings, err := s.Readings(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(readings, last) {
+			if err := o.print(readings, nil); err != nil {
+				return err
+			}
+			last = readings
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
 
-	thing, err := pn532.NewPn532(ctx, deps, sensor.Named("foo"), &cfg, logger)
+// status writes a prompt to stderr, keeping stdout to results.
+func (o *options) status(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// print writes result as one JSON line with --json, and otherwise as sorted
+// key: value lines followed by a blank line.
+func (o *options) print(result map[string]interface{}, err error) error {
 	if err != nil {
 		return err
 	}
-	defer thing.Close(ctx)
+	if o.json {
+		return json.NewEncoder(os.Stdout).Encode(result)
+	}
+	keys := make([]string, 0, len(result))
+	for k := range result {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s: %s\n", k, formatValue(result[k]))
+	}
+	fmt.Println()
+	return nil
+}
 
+// printEvent writes a scan event on one line.
+func (o *options) printEvent(event map[string]interface{}) error {
+	if o.json {
+		return json.NewEncoder(os.Stdout).Encode(event)
+	}
+	line := fmt.Sprintf("%v %v", event["timestamp"], event["event"])
+	if uid, ok := event["uid"]; ok {
+		line += fmt.Sprintf(" uid=%v type=%v", uid, event["tag_type"])
+	}
+	if dwell, ok := event["dwell_ms"]; ok {
+		line += fmt.Sprintf(" dwell_ms=%v", dwell)
+	}
+	if msg, ok := event["error"]; ok {
+		line += fmt.Sprintf(" error=%q", msg)
+	}
+	fmt.Println(line)
 	return nil
 }
+
+// formatValue prints scalars as-is and anything nested as compact JSON.
+func formatValue(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// toFloat converts an in-process DoCommand number, which keeps its Go type,
+// to the float64 the handlers expect back.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case uint64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
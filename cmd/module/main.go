@@ -1,13 +1,18 @@
 package main
 
 import (
-	"pn532"
+	sensor "go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/module"
 	"go.viam.com/rdk/resource"
-	sensor "go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/services/discovery"
+
+	"pn532"
 )
 
 func main() {
 	// ModularMain can take multiple APIModel arguments, if your module implements multiple models.
-	module.ModularMain(resource.APIModel{sensor.API, pn532.Model})
+	module.ModularMain(
+		resource.APIModel{API: sensor.API, Model: pn532.Model},
+		resource.APIModel{API: discovery.API, Model: pn532.DiscoveryModel},
+	)
 }
@@ -5,17 +5,60 @@ import (
 	"slices"
 )
 
-var validTransports = []string{"uart", "i2c", "spi"}
+var validTransports = []string{"auto", "uart", "i2c", "spi"}
 
 // Config holds the configuration for the PN532 sensor component.
 type Config struct {
-	Transport           string `json:"transport"`
-	DevicePath          string `json:"device_path"`
-	PollIntervalMs      int    `json:"poll_interval_ms,omitempty"`
-	CardRemovalTimeoutMs int   `json:"card_removal_timeout_ms,omitempty"`
-	ReadNDEF            *bool  `json:"read_ndef,omitempty"`
-	Debug               bool   `json:"debug,omitempty"`
-	ConnectTimeoutSec   int    `json:"connect_timeout_sec,omitempty"`
+	Transport            string `json:"transport"`
+	DevicePath           string `json:"device_path"`
+	PollIntervalMs       int    `json:"poll_interval_ms,omitempty"`
+	CardRemovalTimeoutMs int    `json:"card_removal_timeout_ms,omitempty"`
+	ReadNDEF             *bool  `json:"read_ndef,omitempty"`
+	Debug                bool   `json:"debug,omitempty"`
+	ConnectTimeoutSec    int    `json:"connect_timeout_sec,omitempty"`
+	RegistryPath         string `json:"registry_path,omitempty"`
+	DumpDir              string `json:"dump_dir,omitempty"`
+
+	// MIFAREKeys are MIFARE Classic sector keys (12 hex digits each), tried
+	// before keys added with add_key and the well-known defaults.
+	MIFAREKeys []string `json:"mifare_keys,omitempty"`
+
+	// NTAGPassword (8 hex digits) authenticates NTAG21x tags on detection
+	// and before writes. NTAGPack (4 hex digits), if set, must match the
+	// tag's answer.
+	NTAGPassword string `json:"ntag_password,omitempty"`
+	NTAGPack     string `json:"ntag_pack,omitempty"`
+
+	// NTAG 424 DNA Secure Dynamic Messaging: AES-128 keys (32 hex digits)
+	// and the URL query parameters carrying the mirrored PICCData and
+	// SDMMAC.
+	SDMMetaReadKey   string `json:"sdm_meta_read_key,omitempty"`
+	SDMFileReadKey   string `json:"sdm_file_read_key,omitempty"`
+	SDMPICCDataParam string `json:"sdm_picc_data_param,omitempty"`
+	SDMCMACParam     string `json:"sdm_cmac_param,omitempty"`
+
+	// PollMode selects tag discovery: "session" polls from the host,
+	// "autopoll" runs the PN532's InAutoPoll over AutopollTargets.
+	PollMode         string   `json:"poll_mode,omitempty"`
+	AutopollTargets  []string `json:"autopoll_targets,omitempty"`
+	AutopollPeriodMs int      `json:"autopoll_period_ms,omitempty"`
+	AutopollCount    int      `json:"autopoll_count,omitempty"`
+
+	// Access control. Groups maps a group name to its member UIDs; groups
+	// take effect through allow_groups and deny_groups.
+	AllowUIDs   []string            `json:"allow_uids,omitempty"`
+	DenyUIDs    []string            `json:"deny_uids,omitempty"`
+	Groups      map[string][]string `json:"groups,omitempty"`
+	AllowGroups []string            `json:"allow_groups,omitempty"`
+	DenyGroups  []string            `json:"deny_groups,omitempty"`
+
+	// Board hosts the optional door strike relay pulsed on each granted
+	// detection and the optional PN532 IRQ input.
+	Board         string `json:"board,omitempty"`
+	RelayPin      string `json:"relay_pin,omitempty"`
+	RelayPulseMs  int    `json:"relay_pulse_ms,omitempty"`
+	IRQPin        string `json:"irq_pin,omitempty"`
+	IRQFallbackMs int    `json:"irq_fallback_ms,omitempty"`
 }
 
 func (cfg *Config) Validate(path string) ([]string, []string, error) {
@@ -25,9 +68,73 @@ func (cfg *Config) Validate(path string) ([]string, []string, error) {
 	if !slices.Contains(validTransports, cfg.Transport) {
 		return nil, nil, fmt.Errorf("invalid transport %q, must be one of %v", cfg.Transport, validTransports)
 	}
-	if cfg.DevicePath == "" {
+	if cfg.DevicePath == "" && cfg.Transport != transportAuto {
 		return nil, nil, fmt.Errorf("device_path is required when transport is %q", cfg.Transport)
 	}
+	if err := validateDevicePath(cfg.DevicePath, cfg.Transport); err != nil {
+		return nil, nil, fmt.Errorf("device_path: %w", err)
+	}
+
+	if cfg.PollMode != "" && !slices.Contains(validPollModes, cfg.PollMode) {
+		return nil, nil, fmt.Errorf("invalid poll_mode %q, must be one of %v", cfg.PollMode, validPollModes)
+	}
+	for _, name := range cfg.AutopollTargets {
+		if _, ok := autopollTargetTypes[name]; !ok {
+			return nil, nil, fmt.Errorf("invalid autopoll_targets entry %q, must be one of %v", name, defaultAutopollTargets)
+		}
+	}
+	if cfg.AutopollPeriodMs != 0 && (cfg.AutopollPeriodMs < minAutopollPeriodMs || cfg.AutopollPeriodMs > maxAutopollPeriodMs) {
+		return nil, nil, fmt.Errorf("autopoll_period_ms must be between %d and %d", minAutopollPeriodMs, maxAutopollPeriodMs)
+	}
+	if cfg.AutopollCount < 0 || cfg.AutopollCount > maxAutopollCount {
+		return nil, nil, fmt.Errorf("autopoll_count must be between 1 and %d", maxAutopollCount)
+	}
+	if cfg.IRQPin != "" && cfg.PollMode == pollModeAutopoll {
+		return nil, nil, fmt.Errorf("irq_pin is not supported with poll_mode %q", pollModeAutopoll)
+	}
+
+	for i, k := range cfg.MIFAREKeys {
+		if _, err := parseMIFAREKey(k); err != nil {
+			return nil, nil, fmt.Errorf("mifare_keys[%d]: %w", i, err)
+		}
+	}
+
+	if cfg.NTAGPassword != "" {
+		if _, _, err := parseNTAGPassword(cfg.NTAGPassword, cfg.NTAGPack); err != nil {
+			return nil, nil, fmt.Errorf("ntag_password: %w", err)
+		}
+	} else if cfg.NTAGPack != "" {
+		return nil, nil, fmt.Errorf("ntag_pack requires ntag_password")
+	}
+
+	if cfg.SDMMetaReadKey != "" || cfg.SDMFileReadKey != "" {
+		if _, err := parseSDMKey(cfg.SDMMetaReadKey); err != nil {
+			return nil, nil, fmt.Errorf("sdm_meta_read_key: %w", err)
+		}
+		if _, err := parseSDMKey(cfg.SDMFileReadKey); err != nil {
+			return nil, nil, fmt.Errorf("sdm_file_read_key: %w", err)
+		}
+	}
+
+	for _, g := range append(slices.Clone(cfg.AllowGroups), cfg.DenyGroups...) {
+		if _, ok := cfg.Groups[g]; !ok {
+			return nil, nil, fmt.Errorf("group %q is not defined in groups", g)
+		}
+	}
+
+	var deps []string
+	if cfg.RelayPin != "" && cfg.Board == "" {
+		return nil, nil, fmt.Errorf("board is required when relay_pin is set")
+	}
+	if cfg.RelayPin != "" && !hasAccessLists(cfg) {
+		return nil, nil, fmt.Errorf("relay_pin requires allow_uids, deny_uids, allow_groups or deny_groups; without them no detection is granted")
+	}
+	if cfg.IRQPin != "" && cfg.Board == "" {
+		return nil, nil, fmt.Errorf("board is required when irq_pin is set")
+	}
+	if cfg.RelayPin != "" || cfg.IRQPin != "" {
+		deps = append(deps, cfg.Board)
+	}
 
-	return nil, nil, nil
+	return deps, nil, nil
 }
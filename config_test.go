@@ -3,17 +3,20 @@ package pn532
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	pn532lib "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
 	sensor "go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/logging"
 )
 
 func TestValidateTransportValues(t *testing.T) {
-	valid := []string{"uart", "i2c", "spi"}
+	valid := []string{"auto", "uart", "i2c", "spi"}
 	for _, transport := range valid {
 		cfg := &Config{Transport: transport, DevicePath: "/dev/test"}
 		_, _, err := cfg.Validate("test")
@@ -22,7 +25,7 @@ func TestValidateTransportValues(t *testing.T) {
 		}
 	}
 
-	invalid := []string{"", "auto", "usb", "bluetooth", "serial", "UART"}
+	invalid := []string{"", "usb", "bluetooth", "serial", "UART"}
 	for _, transport := range invalid {
 		cfg := &Config{Transport: transport, DevicePath: "/dev/test"}
 		_, _, err := cfg.Validate("test")
@@ -32,6 +35,88 @@ func TestValidateTransportValues(t *testing.T) {
 	}
 }
 
+func TestValidateAccessControl(t *testing.T) {
+	cfg := &Config{
+		Transport:   "i2c",
+		DevicePath:  "/dev/i2c-1",
+		Groups:      map[string][]string{"staff": {"04aaaaaa"}},
+		AllowGroups: []string{"staff"},
+		Board:       "pi",
+		RelayPin:    "37",
+	}
+	deps, _, err := cfg.Validate("test")
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+	if len(deps) != 1 || deps[0] != "pi" {
+		t.Errorf("deps = %v, want [pi]", deps)
+	}
+
+	cfg.DenyGroups = []string{"contractors"}
+	if _, _, err := cfg.Validate("test"); err == nil {
+		t.Error("undefined group in deny_groups should fail validation")
+	}
+
+	cfg.DenyGroups = nil
+	cfg.Board = ""
+	if _, _, err := cfg.Validate("test"); err == nil {
+		t.Error("relay_pin without board should fail validation")
+	}
+
+	// Without any list accessGranted is never set, so the relay would never
+	// pulse.
+	cfg = &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", Board: "pi", RelayPin: "37"}
+	if _, _, err := cfg.Validate("test"); err == nil || !strings.Contains(err.Error(), "relay_pin requires") {
+		t.Errorf("relay_pin without access lists: Validate = %v", err)
+	}
+	cfg.DenyUIDs = []string{"04aaaaaa"}
+	if _, _, err := cfg.Validate("test"); err != nil {
+		t.Errorf("relay_pin with deny_uids: Validate = %v", err)
+	}
+}
+
+func TestValidateIRQPinRequiresBoard(t *testing.T) {
+	cfg := &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", IRQPin: "18"}
+	if _, _, err := cfg.Validate("test"); err == nil {
+		t.Fatal("irq_pin without board should fail validation")
+	}
+
+	cfg.Board = "pi"
+	deps, _, err := cfg.Validate("test")
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+	if len(deps) != 1 || deps[0] != "pi" {
+		t.Errorf("deps = %v, want [pi]", deps)
+	}
+}
+
+func TestValidatePollMode(t *testing.T) {
+	base := func() *Config {
+		return &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", PollMode: pollModeAutopoll}
+	}
+
+	if _, _, err := base().Validate("test"); err != nil {
+		t.Errorf("autopoll with defaults should pass validation: %v", err)
+	}
+
+	invalid := map[string]func(*Config){
+		"unknown mode":   func(c *Config) { c.PollMode = "interrupt" },
+		"unknown target": func(c *Config) { c.AutopollTargets = []string{"iso14443a", "iso15693"} },
+		"period too low": func(c *Config) { c.AutopollPeriodMs = 100 },
+		"period too big": func(c *Config) { c.AutopollPeriodMs = 3000 },
+		"endless count":  func(c *Config) { c.AutopollCount = 255 },
+		"irq pin":        func(c *Config) { c.Board, c.IRQPin = "pi", "18" },
+	}
+	for name, mutate := range invalid {
+		cfg := base()
+		mutate(cfg)
+		if _, _, err := cfg.Validate("test"); err == nil {
+			t.Errorf("%s should fail validation", name)
+		}
+	}
+}
+
 func TestValidateDevicePathRequirement(t *testing.T) {
 	for _, transport := range []string{"uart", "i2c", "spi"} {
 		cfg := &Config{Transport: transport}
@@ -47,6 +132,10 @@ func TestValidateDevicePathRequirement(t *testing.T) {
 		}
 	}
 
+	if _, _, err := (&Config{Transport: "auto"}).Validate("test"); err != nil {
+		t.Errorf("transport auto with no device_path should pass: %v", err)
+	}
+
 	cfg := &Config{}
 	_, _, err := cfg.Validate("test")
 	if err == nil {
@@ -62,12 +151,12 @@ func newTestSensor(t *testing.T, cfg *Config) *pn532Sensor {
 	resolved := applyConfigDefaults(cfg)
 
 	return &pn532Sensor{
-		name:       sensor.Named("test"),
-		logger:     logging.NewTestLogger(t),
-		cfg:        resolved,
-		cancelCtx:  cancelCtx,
-		cancelFunc: cancelFunc,
-		scanNotify: make(chan tagState, 1),
+		name:          sensor.Named("test"),
+		logger:        logging.NewTestLogger(t),
+		cfg:           resolved,
+		cancelCtx:     cancelCtx,
+		cancelFunc:    cancelFunc,
+		sessionExited: make(chan error, 1),
 	}
 }
 
@@ -86,14 +175,15 @@ func newTestSensorWithDevice(t *testing.T, cfg *Config) (*pn532Sensor, *pn532lib
 	resolved := applyConfigDefaults(cfg)
 
 	s := &pn532Sensor{
-		name:       sensor.Named("test"),
-		logger:     logging.NewTestLogger(t),
-		cfg:        resolved,
-		device:     device,
-		cancelCtx:  cancelCtx,
-		cancelFunc: cancelFunc,
-		scanNotify: make(chan tagState, 1),
-		state:      tagState{deviceHealthy: true},
+		name:          sensor.Named("test"),
+		logger:        logging.NewTestLogger(t),
+		cfg:           resolved,
+		device:        device,
+		cancelCtx:     cancelCtx,
+		cancelFunc:    cancelFunc,
+		sessionExited: make(chan error, 1),
+		state:         tagState{deviceHealthy: true},
+		keys:          &mifareKeyStore{},
 	}
 	return s, mock
 }
@@ -112,6 +202,9 @@ func TestValidateDefaults(t *testing.T) {
 	if s.cfg.ReadNDEF == nil || !*s.cfg.ReadNDEF {
 		t.Error("ReadNDEF should default to true")
 	}
+	if s.cfg.PollMode != pollModeSession {
+		t.Errorf("PollMode = %q, want %q", s.cfg.PollMode, pollModeSession)
+	}
 
 	readNDEF := false
 	s2 := newTestSensor(t, &Config{
@@ -246,21 +339,22 @@ func TestBuildReadingsTagPresent(t *testing.T) {
 		userMemoryBytes: 504,
 		ndefText:        "hello",
 		ndefRecordCount: 1,
+		ndefRecords:     []*ndef.Record{ndef.NewTextRecord("hello", "en")},
 	}
 	readings := buildReadingsFromState(state)
 
 	checks := map[string]interface{}{
-		"status":           "connected",
-		"device_healthy":   true,
-		"tag_present":      true,
-		"uid":              "04abcdef123456",
-		"tag_type":         "NTAG",
-		"manufacturer":     "NXP",
-		"is_genuine":       true,
-		"ntag_variant":     "NTAG215",
-		"mifare_variant":   "",
+		"status":            "connected",
+		"device_healthy":    true,
+		"tag_present":       true,
+		"uid":               "04abcdef123456",
+		"tag_type":          "NTAG",
+		"manufacturer":      "NXP",
+		"is_genuine":        true,
+		"ntag_variant":      "NTAG215",
+		"mifare_variant":    "",
 		"user_memory_bytes": 504,
-		"ndef_text":        "hello",
+		"ndef_text":         "hello",
 		"ndef_record_count": 1,
 	}
 	for key, want := range checks {
@@ -273,6 +367,14 @@ func TestBuildReadingsTagPresent(t *testing.T) {
 			t.Errorf("readings[%q] = %v, want %v", key, got, want)
 		}
 	}
+
+	records, ok := readings["ndef_records"].([]interface{})
+	if !ok || len(records) != 1 {
+		t.Fatalf("ndef_records = %v, want one record", readings["ndef_records"])
+	}
+	if text := records[0].(map[string]interface{})["text"]; text != "hello" {
+		t.Errorf("ndef_records[0].text = %v, want hello", text)
+	}
 }
 
 func TestBuildReadingsDeviceUnhealthyState(t *testing.T) {
@@ -557,3 +659,127 @@ func TestCloseStopsSession(t *testing.T) {
 		t.Fatal("Close deadlocked — did not complete within 2 seconds")
 	}
 }
+
+// newMockDevice returns a PN532 device backed by a fresh MockTransport.
+func newMockDevice(t *testing.T) *pn532lib.Device {
+	t.Helper()
+	device, err := pn532lib.New(pn532lib.NewMockTransport())
+	if err != nil {
+		t.Fatalf("failed to create mock device: %v", err)
+	}
+	return device
+}
+
+// waitFor polls cond until it returns true or the deadline passes.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestReconnectAfterSessionExit(t *testing.T) {
+	s, _ := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	var attempts atomic.Int32
+	s.connect = func(ctx context.Context) (*pn532lib.Device, error) {
+		if attempts.Add(1) == 1 {
+			return nil, errors.New("no ACK")
+		}
+		return newMockDevice(t), nil
+	}
+	s.supervisorWg.Add(1)
+	go s.superviseConnection()
+	t.Cleanup(func() { _ = s.Close(context.Background()) })
+
+	s.onDeviceDisconnected(errors.New("transport gone"))
+	s.sessionExited <- errors.New("transport gone")
+
+	ok := waitFor(t, 3*time.Second, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.state.deviceHealthy && s.session != nil
+	})
+	if !ok {
+		t.Fatal("sensor did not reconnect")
+	}
+
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	if readings["reconnect_attempts"] != 2 {
+		t.Errorf("reconnect_attempts = %v, want 2", readings["reconnect_attempts"])
+	}
+	if readings["last_disconnect_error"] != "transport gone" {
+		t.Errorf("last_disconnect_error = %v, want \"transport gone\"", readings["last_disconnect_error"])
+	}
+	if since, _ := readings["connected_since"].(string); since == "" {
+		t.Error("connected_since should be set after reconnect")
+	}
+}
+
+func TestCloseDuringReconnect(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	connecting := make(chan struct{})
+	s.connect = func(ctx context.Context) (*pn532lib.Device, error) {
+		close(connecting)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	s.supervisorWg.Add(1)
+	go s.superviseConnection()
+
+	s.sessionExited <- errors.New("transport gone")
+	<-connecting
+
+	done := make(chan struct{})
+	go func() {
+		_ = s.Close(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not interrupt reconnect")
+	}
+}
+
+func TestBuildReadingsDeviceFields(t *testing.T) {
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	state := &tagState{
+		deviceHealthy:       true,
+		reconnectAttempts:   3,
+		lastDisconnectError: "no ACK",
+		connectedSince:      since,
+		transport:           "i2c",
+		devicePath:          "/dev/i2c-1",
+	}
+	readings := buildReadingsFromState(state)
+	if readings["transport"] != "i2c" || readings["device_path"] != "/dev/i2c-1" {
+		t.Errorf("transport, device_path = %v, %v", readings["transport"], readings["device_path"])
+	}
+
+	if readings["reconnect_attempts"] != 3 {
+		t.Errorf("reconnect_attempts = %v, want 3", readings["reconnect_attempts"])
+	}
+	if readings["last_disconnect_error"] != "no ACK" {
+		t.Errorf("last_disconnect_error = %v, want \"no ACK\"", readings["last_disconnect_error"])
+	}
+	if readings["connected_since"] != "2026-01-02T03:04:05Z" {
+		t.Errorf("connected_since = %v, want 2026-01-02T03:04:05Z", readings["connected_since"])
+	}
+
+	state.deviceHealthy = false
+	readings = buildReadingsFromState(state)
+	if readings["connected_since"] != "" {
+		t.Errorf("connected_since = %v, want empty while disconnected", readings["connected_since"])
+	}
+}
@@ -0,0 +1,208 @@
+package pn532
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// usbSelectorPrefix starts a device_path that names a USB serial adapter by
+// vendor and product ID, and optionally serial number, instead of its node.
+const usbSelectorPrefix = "usb:"
+
+// sysfsRoot is where USB serial ports are looked up for usb: selectors.
+const sysfsRoot = "/sys"
+
+var usbIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{4}$`)
+
+// usbSelector is a parsed usb:VID:PID[:serial] device_path.
+type usbSelector struct {
+	vendorID  string
+	productID string
+	serial    string
+}
+
+func (u usbSelector) String() string {
+	s := usbSelectorPrefix + u.vendorID + ":" + u.productID
+	if u.serial != "" {
+		s += ":" + u.serial
+	}
+	return s
+}
+
+// parseUSBSelector parses usb:VID:PID[:serial], with VID and PID as four hex
+// digits each.
+func parseUSBSelector(devicePath string) (usbSelector, error) {
+	parts := strings.SplitN(strings.TrimPrefix(devicePath, usbSelectorPrefix), ":", 3)
+	if len(parts) < 2 || !usbIDPattern.MatchString(parts[0]) || !usbIDPattern.MatchString(parts[1]) {
+		return usbSelector{}, fmt.Errorf("%q must be usb:VID:PID or usb:VID:PID:serial with VID and PID as 4 hex digits", devicePath)
+	}
+	sel := usbSelector{vendorID: strings.ToLower(parts[0]), productID: strings.ToLower(parts[1])}
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return usbSelector{}, fmt.Errorf("%q has an empty serial number", devicePath)
+		}
+		sel.serial = parts[2]
+	}
+	return sel, nil
+}
+
+func isUSBSelector(devicePath string) bool {
+	return strings.HasPrefix(devicePath, usbSelectorPrefix)
+}
+
+func isGlobPattern(devicePath string) bool {
+	return strings.ContainsAny(devicePath, "*?[")
+}
+
+// validateDevicePath checks the syntax of a usb: selector or glob pattern in
+// device_path; which device it names is only known when connecting.
+func validateDevicePath(devicePath, transport string) error {
+	switch {
+	case isUSBSelector(devicePath):
+		if transport != "uart" && transport != transportAuto {
+			return fmt.Errorf("usb: selectors name USB serial adapters and need transport \"uart\" or \"auto\", not %q", transport)
+		}
+		_, err := parseUSBSelector(devicePath)
+		return err
+	case isGlobPattern(devicePath):
+		if _, err := filepath.Match(devicePath, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", devicePath, err)
+		}
+	}
+	return nil
+}
+
+// usbSerialPort is a tty backed by a USB device.
+type usbSerialPort struct {
+	path      string
+	vendorID  string
+	productID string
+	serial    string
+}
+
+// listUSBSerialPorts returns the ttys under sysRoot whose device has a USB
+// ancestor, with that ancestor's IDs and serial number.
+func listUSBSerialPorts(sysRoot string) ([]usbSerialPort, error) {
+	ttys, err := filepath.Glob(filepath.Join(sysRoot, "class", "tty", "*"))
+	if err != nil {
+		return nil, err
+	}
+	devicesRoot := filepath.Join(sysRoot, "devices")
+	var ports []usbSerialPort
+	for _, tty := range ttys {
+		dir, err := filepath.EvalSymlinks(filepath.Join(tty, "device"))
+		if err != nil {
+			continue
+		}
+		// Walk up from the interface to the USB device, which has idVendor.
+		for ; strings.HasPrefix(dir, devicesRoot+string(filepath.Separator)); dir = filepath.Dir(dir) {
+			vendorID, err := readSysfsAttr(dir, "idVendor")
+			if err != nil {
+				continue
+			}
+			productID, _ := readSysfsAttr(dir, "idProduct")
+			serial, _ := readSysfsAttr(dir, "serial")
+			ports = append(ports, usbSerialPort{
+				path:      "/dev/" + filepath.Base(tty),
+				vendorID:  strings.ToLower(vendorID),
+				productID: strings.ToLower(productID),
+				serial:    serial,
+			})
+			break
+		}
+	}
+	return ports, nil
+}
+
+func readSysfsAttr(dir, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	return strings.TrimSpace(string(data)), err
+}
+
+// devicePathResolver turns a device_path into the node to open. Its lookups
+// are fields so tests can stand in for /dev and sysfs.
+type devicePathResolver struct {
+	glob     func(pattern string) ([]string, error)
+	usbPorts func() ([]usbSerialPort, error)
+}
+
+var defaultDevicePathResolver = devicePathResolver{
+	glob:     filepath.Glob,
+	usbPorts: func() ([]usbSerialPort, error) { return listUSBSerialPorts(sysfsRoot) },
+}
+
+// resolve returns the single node matched by a glob pattern or usb:
+// selector, and any other path unchanged. It is called on every connect, so
+// a reader that comes back under a different node after a reboot or replug
+// is still found.
+func (r devicePathResolver) resolve(devicePath string) (string, error) {
+	switch {
+	case isUSBSelector(devicePath):
+		return r.resolveUSB(devicePath)
+	case isGlobPattern(devicePath):
+		return r.resolveGlob(devicePath)
+	default:
+		return devicePath, nil
+	}
+}
+
+func (r devicePathResolver) resolveGlob(pattern string) (string, error) {
+	paths, err := r.glob(pattern)
+	if err != nil {
+		return "", fmt.Errorf("device_path: invalid pattern %q: %w", pattern, err)
+	}
+	// Links such as /dev/serial/by-id entries count once per device.
+	var matches []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if target := canonicalDevicePath(path); !seen[target] {
+			seen[target] = true
+			matches = append(matches, path)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("device_path: no device matches %q", pattern)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("device_path: %q matches %d devices (%s); use a narrower pattern or a usb:VID:PID:serial selector",
+			pattern, len(matches), strings.Join(matches, ", "))
+	}
+}
+
+func (r devicePathResolver) resolveUSB(devicePath string) (string, error) {
+	sel, err := parseUSBSelector(devicePath)
+	if err != nil {
+		return "", fmt.Errorf("device_path: %w", err)
+	}
+	ports, err := r.usbPorts()
+	if err != nil {
+		return "", fmt.Errorf("device_path: listing USB serial ports: %w", err)
+	}
+	var matches []usbSerialPort
+	for _, p := range ports {
+		if p.vendorID == sel.vendorID && p.productID == sel.productID && (sel.serial == "" || p.serial == sel.serial) {
+			matches = append(matches, p)
+		}
+	}
+	slices.SortFunc(matches, func(a, b usbSerialPort) int { return strings.Compare(a.path, b.path) })
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("device_path: no USB serial device matches %s", sel)
+	case 1:
+		return matches[0].path, nil
+	default:
+		found := make([]string, len(matches))
+		for i, m := range matches {
+			found[i] = fmt.Sprintf("%s serial %q", m.path, m.serial)
+		}
+		return "", fmt.Errorf("device_path: %s matches %d devices (%s); add the serial number, as %s:<serial>",
+			sel, len(matches), strings.Join(found, ", "), sel)
+	}
+}
@@ -0,0 +1,157 @@
+package pn532
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateDevicePath(t *testing.T) {
+	tests := []struct {
+		transport, path string
+		wantErr         string
+	}{
+		{"uart", "/dev/ttyUSB0", ""},
+		{"uart", "/dev/serial/by-id/usb-Silicon_Labs_CP2102-if00-port0", ""},
+		{"uart", "/dev/ttyUSB*", ""},
+		{"uart", "usb:10c4:ea60", ""},
+		{"auto", "usb:10C4:EA60:0001", ""},
+		{"uart", "/dev/ttyUSB[", "invalid pattern"},
+		{"uart", "usb:10c4", "usb:VID:PID"},
+		{"uart", "usb:10c4:ea6", "usb:VID:PID"},
+		{"uart", "usb:10c4:ea60:", "empty serial"},
+		{"i2c", "usb:10c4:ea60", "transport"},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Transport: tt.transport, DevicePath: tt.path}
+		_, _, err := cfg.Validate("")
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("Validate(%s, %q) = %v", tt.transport, tt.path, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("Validate(%s, %q) = %v, want error containing %q", tt.transport, tt.path, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveDevicePathGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ttyUSB0", "ttyUSB1", "by-id"} {
+		if name == "by-id" {
+			if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two links to the same adapter, as udev makes for some devices.
+	link := filepath.Join(dir, "by-id", "usb-Silicon_Labs_CP2102_0001-if00-port0")
+	for _, l := range []string{link, link + "-alt"} {
+		if err := os.Symlink(filepath.Join(dir, "ttyUSB1"), l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := devicePathResolver{glob: filepath.Glob}
+	got, err := r.resolve(filepath.Join(dir, "by-id", "usb-Silicon_Labs*"))
+	if err != nil || got != link {
+		t.Errorf("resolve(by-id pattern) = %q, %v, want %q", got, err, link)
+	}
+	if got, err := r.resolve(filepath.Join(dir, "ttyUSB0")); err != nil || got != filepath.Join(dir, "ttyUSB0") {
+		t.Errorf("resolve(exact path) = %q, %v", got, err)
+	}
+	if _, err := r.resolve(filepath.Join(dir, "ttyUSB*")); err == nil || !strings.Contains(err.Error(), "matches 2 devices") {
+		t.Errorf("ambiguous pattern: got %v", err)
+	}
+	if _, err := r.resolve(filepath.Join(dir, "ttyACM*")); err == nil || !strings.Contains(err.Error(), "no device matches") {
+		t.Errorf("unmatched pattern: got %v", err)
+	}
+}
+
+// writeFakeUSBSerial adds a USB serial tty to a fake sysfs tree under root.
+func writeFakeUSBSerial(t *testing.T, root, tty, usbDev, vendor, product, serial string) {
+	t.Helper()
+	dev := filepath.Join(root, "devices", "platform", "usb1", usbDev)
+	iface := filepath.Join(dev, usbDev+":1.0", tty)
+	if err := os.MkdirAll(iface, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	attrs := map[string]string{"idVendor": vendor, "idProduct": product}
+	if serial != "" {
+		attrs["serial"] = serial
+	}
+	for name, value := range attrs {
+		if err := os.WriteFile(filepath.Join(dev, name), []byte(value+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	class := filepath.Join(root, "class", "tty", tty)
+	if err := os.MkdirAll(class, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(iface, filepath.Join(class, "device")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListUSBSerialPorts(t *testing.T) {
+	root := t.TempDir()
+	writeFakeUSBSerial(t, root, "ttyUSB0", "1-1", "10c4", "ea60", "0001")
+	writeFakeUSBSerial(t, root, "ttyUSB1", "1-2", "1A86", "7523", "")
+	// A tty with no device, like a virtual console.
+	if err := os.MkdirAll(filepath.Join(root, "class", "tty", "tty1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ports, err := listUSBSerialPorts(root)
+	if err != nil {
+		t.Fatalf("listUSBSerialPorts: %v", err)
+	}
+	want := []usbSerialPort{
+		{path: "/dev/ttyUSB0", vendorID: "10c4", productID: "ea60", serial: "0001"},
+		{path: "/dev/ttyUSB1", vendorID: "1a86", productID: "7523"},
+	}
+	if len(ports) != len(want) {
+		t.Fatalf("ports = %v, want %v", ports, want)
+	}
+	for i := range want {
+		if ports[i] != want[i] {
+			t.Errorf("port %d = %+v, want %+v", i, ports[i], want[i])
+		}
+	}
+}
+
+func TestResolveDevicePathUSB(t *testing.T) {
+	r := devicePathResolver{usbPorts: func() ([]usbSerialPort, error) {
+		return []usbSerialPort{
+			{path: "/dev/ttyUSB1", vendorID: "10c4", productID: "ea60", serial: "0002"},
+			{path: "/dev/ttyUSB0", vendorID: "10c4", productID: "ea60", serial: "0001"},
+			{path: "/dev/ttyUSB2", vendorID: "1a86", productID: "7523"},
+		}, nil
+	}}
+
+	tests := []struct {
+		selector, want, wantErr string
+	}{
+		{"usb:1A86:7523", "/dev/ttyUSB2", ""},
+		{"usb:10c4:ea60:0002", "/dev/ttyUSB1", ""},
+		{"usb:10c4:ea60", "", "matches 2 devices (/dev/ttyUSB0 serial \"0001\", /dev/ttyUSB1 serial \"0002\")"},
+		{"usb:10c4:ea60:0003", "", "no USB serial device matches usb:10c4:ea60:0003"},
+	}
+	for _, tt := range tests {
+		got, err := r.resolve(tt.selector)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolve(%s) = %q, %v, want error containing %q", tt.selector, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolve(%s) = %q, %v, want %q", tt.selector, got, err, tt.want)
+		}
+	}
+}
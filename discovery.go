@@ -0,0 +1,105 @@
+package pn532
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	sensor "go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/discovery"
+	"go.viam.com/rdk/utils"
+)
+
+var DiscoveryModel = resource.NewModel("viam", "nfc", "pn532-discovery")
+
+func init() {
+	resource.RegisterService(discovery.API, DiscoveryModel,
+		resource.Registration[discovery.Service, resource.NoNativeConfig]{
+			Constructor: newPn532Discovery,
+		},
+	)
+}
+
+// pn532Discovery finds PN532 readers attached to the machine.
+type pn532Discovery struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	logger logging.Logger
+	// probe is probePN532 in production and is swapped out in tests.
+	probe probeFunc
+	glob  func(pattern string) ([]string, error)
+}
+
+func newPn532Discovery(_ context.Context, _ resource.Dependencies, rawConf resource.Config, logger logging.Logger) (discovery.Service, error) {
+	return &pn532Discovery{
+		Named:  rawConf.ResourceName().AsNamed(),
+		logger: logger,
+		probe:  probePN532,
+		glob:   filepath.Glob,
+	}, nil
+}
+
+// DiscoverResources probes the same device paths as transport "auto" and
+// returns a viam:nfc:pn532 sensor config for each PN532 that answers. Paths
+// held open by a sensor in this module are skipped.
+func (d *pn532Discovery) DiscoverResources(ctx context.Context, _ map[string]any) ([]resource.Config, error) {
+	var configs []resource.Config
+	for _, c := range autoDetectCandidates("", d.glob) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if openDevices.holds(c.path) {
+			d.logger.Debugw("skipping device in use", "device_path", c.path)
+			continue
+		}
+		fw, err := d.probe(ctx, c)
+		if err != nil {
+			d.logger.Debugw("no PN532 found", "transport", c.transport, "device_path", c.path, "error", err)
+			continue
+		}
+		if c.transport == "uart" {
+			c.path = d.stablePath(c.path)
+		}
+		d.logger.Infow("discovered PN532", "transport", c.transport, "device_path", c.path, "firmware_version", fw.Version)
+		configs = append(configs, discoveredConfig(len(configs)+1, c))
+	}
+	return configs, nil
+}
+
+// serialByIDPattern matches the udev links that name a USB serial adapter by
+// vendor, product and serial number rather than enumeration order.
+const serialByIDPattern = "/dev/serial/by-id/*"
+
+// stablePath returns the /dev/serial/by-id link to the serial port at path,
+// or path if there is none, so a discovered config survives the adapter
+// coming back as a different ttyUSB node.
+func (d *pn532Discovery) stablePath(path string) string {
+	links, err := d.glob(serialByIDPattern)
+	if err != nil {
+		return path
+	}
+	target := canonicalDevicePath(path)
+	for _, link := range links {
+		if canonicalDevicePath(link) == target {
+			return link
+		}
+	}
+	return path
+}
+
+// discoveredConfig is the sensor config for the n-th reader found.
+func discoveredConfig(n int, c transportCandidate) resource.Config {
+	return resource.Config{
+		Name:  fmt.Sprintf("nfc-reader-%d", n),
+		API:   sensor.API,
+		Model: Model,
+		Attributes: utils.AttributeMap{
+			"transport":   c.transport,
+			"device_path": c.path,
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	sensor "go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+)
+
+func TestDiscoverResources(t *testing.T) {
+	devices := map[string][]string{
+		"/dev/i2c-*":   {"/dev/i2c-1", "/dev/i2c-2"},
+		"/dev/ttyUSB*": {"/dev/ttyUSB0", "/dev/ttyUSB1"},
+	}
+	readers := map[string]string{"/dev/i2c-1": "1.6", "/dev/ttyUSB0": "1.6", "/dev/ttyUSB1": "1.6"}
+	var probed []string
+	d := &pn532Discovery{
+		logger: logging.NewTestLogger(t),
+		glob:   func(pattern string) ([]string, error) { return devices[pattern], nil },
+		probe: func(_ context.Context, c transportCandidate) (*pn532lib.FirmwareVersion, error) {
+			probed = append(probed, c.path)
+			if version, ok := readers[c.path]; ok {
+				return &pn532lib.FirmwareVersion{Version: version}, nil
+			}
+			return nil, errors.New("timeout")
+		},
+	}
+
+	// A sensor in this process already has ttyUSB1 open.
+	openDevices.claim("/dev/ttyUSB1")
+	defer openDevices.release("/dev/ttyUSB1")
+
+	configs, err := d.DiscoverResources(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("DiscoverResources: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("found %d readers, want 2: %v", len(configs), configs)
+	}
+	for _, p := range probed {
+		if p == "/dev/ttyUSB1" {
+			t.Error("a device held by a sensor was probed")
+		}
+	}
+
+	want := []struct{ name, transport, path string }{
+		{"nfc-reader-1", "i2c", "/dev/i2c-1"},
+		{"nfc-reader-2", "uart", "/dev/ttyUSB0"},
+	}
+	for i, w := range want {
+		c := configs[i]
+		if c.Name != w.name || c.API != sensor.API || c.Model != Model {
+			t.Errorf("config %d = %s %s %s", i, c.Name, c.API, c.Model)
+		}
+		if c.Attributes["transport"] != w.transport || c.Attributes["device_path"] != w.path {
+			t.Errorf("config %d attributes = %v", i, c.Attributes)
+		}
+
+		// Every attribute must map to a Config field.
+		raw, err := json.Marshal(c.Attributes)
+		if err != nil {
+			t.Fatalf("config %d: %v", i, err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		var cfg Config
+		if err := dec.Decode(&cfg); err != nil {
+			t.Errorf("config %d attributes do not decode strictly: %v", i, err)
+		}
+		if _, _, err := cfg.Validate(""); err != nil {
+			t.Errorf("config %d does not validate: %v", i, err)
+		}
+	}
+}
+
+func TestDiscoveryStablePath(t *testing.T) {
+	dir := t.TempDir()
+	tty := filepath.Join(dir, "ttyUSB0")
+	link := filepath.Join(dir, "usb-Silicon_Labs_CP2102_0001-if00-port0")
+	if err := os.WriteFile(tty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(tty, link); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &pn532Discovery{
+		logger: logging.NewTestLogger(t),
+		glob: func(pattern string) ([]string, error) {
+			switch pattern {
+			case "/dev/ttyUSB*":
+				return []string{tty}, nil
+			case serialByIDPattern:
+				return []string{link}, nil
+			}
+			return nil, nil
+		},
+		probe: func(context.Context, transportCandidate) (*pn532lib.FirmwareVersion, error) {
+			return &pn532lib.FirmwareVersion{Version: "1.6"}, nil
+		},
+	}
+	configs, err := d.DiscoverResources(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("DiscoverResources: %v", err)
+	}
+	if len(configs) != 1 || configs[0].Attributes["device_path"] != link {
+		t.Errorf("configs = %v, want device_path %s", configs, link)
+	}
+}
@@ -3,11 +3,17 @@ package pn532
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	pn532lib "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/tagops"
 )
 
+// defaultWriteTimeout bounds how long write actions wait for a tag when the
+// caller does not pass timeout_ms.
+const defaultWriteTimeout = 10 * time.Second
+
 func (s *pn532Sensor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
 	action, ok := cmd["action"].(string)
 	if !ok {
@@ -17,6 +23,48 @@ func (s *pn532Sensor) DoCommand(ctx context.Context, cmd map[string]interface{})
 	switch action {
 	case "await_scan":
 		return s.handleAwaitScan(ctx, cmd)
+	case "await_removal":
+		return s.handleAwaitRemoval(ctx, cmd)
+	case "get_events":
+		return s.handleGetEvents(ctx, cmd)
+	case "write_text":
+		return s.handleWriteText(ctx, cmd)
+	case "write_ndef":
+		return s.handleWriteNDEF(ctx, cmd)
+	case "register_tag":
+		return s.handleRegisterTag(cmd)
+	case "unregister_tag":
+		return s.handleUnregisterTag(cmd)
+	case "list_tags":
+		return s.handleListTags()
+	case "get_tag":
+		return s.handleGetTag(cmd)
+	case "add_key":
+		return s.handleAddKey(cmd)
+	case "list_keys":
+		return s.handleListKeys()
+	case "ntag_set_password":
+		return s.handleNTAGSetPassword(ctx, cmd)
+	case "ntag_authenticate":
+		return s.handleNTAGAuthenticate(ctx, cmd)
+	case "ntag_clear_password":
+		return s.handleNTAGClearPassword(ctx, cmd)
+	case "lock_tag":
+		return s.handleLockTag(ctx, cmd)
+	case "dump_tag":
+		return s.handleDumpTag(ctx, cmd)
+	case "restore_tag":
+		return s.handleRestoreTag(ctx, cmd)
+	case "read_pages":
+		return s.handleReadPages(ctx, cmd)
+	case "write_pages":
+		return s.handleWritePages(ctx, cmd)
+	case "mifare_read_block":
+		return s.handleMIFAREReadBlock(ctx, cmd)
+	case "mifare_write_block":
+		return s.handleMIFAREWriteBlock(ctx, cmd)
+	case "mifare_read_sector":
+		return s.handleMIFAREReadSector(ctx, cmd)
 	case "diagnostics":
 		return s.handleDiagnostics(ctx)
 	default:
@@ -24,6 +72,11 @@ func (s *pn532Sensor) DoCommand(ctx context.Context, cmd map[string]interface{})
 	}
 }
 
+// handleAwaitScan blocks until a detection passes the optional filters (see
+// scanFilter) or the timeout expires. Only detections after the call starts
+// are considered unless since is given, in which case detections with a
+// sequence number greater than since are replayed from the event log first.
+// Every concurrent caller sees every detection.
 func (s *pn532Sensor) handleAwaitScan(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
 	waitCtx := ctx
 	if timeoutMs, ok := cmd["timeout_ms"].(float64); ok && timeoutMs > 0 {
@@ -32,22 +85,369 @@ func (s *pn532Sensor) handleAwaitScan(ctx context.Context, cmd map[string]interf
 		defer cancel()
 	}
 
-	// Priority-check: deliver a buffered result even if the deadline is
-	// also ready, since Go's select chooses uniformly at random.
-	select {
-	case snap := <-s.scanNotify:
-		return buildReadingsFromState(&snap), nil
-	default:
+	filter, err := parseScanFilter(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("await_scan: %w", err)
+	}
+	requireDifferent, _ := cmd["require_different_uid"].(bool)
+	requireRemoval, _ := cmd["require_removal_first"].(bool)
+
+	s.mu.RLock()
+	if requireDifferent {
+		if s.state.tagPresent {
+			filter.excludeUID = s.state.uid
+		} else if e, ok := s.events.last(eventDetected); ok {
+			filter.excludeUID = e.uid
+		}
+	}
+	// removed is whether the tag in the field (if any) has left since the
+	// call started; detections before that are skipped.
+	removed := !(requireRemoval && s.state.tagPresent)
+	cursor := s.events.lastSeq
+	s.mu.RUnlock()
+
+	if since, ok := cmd["since"].(float64); ok && since >= 0 && uint64(since) < cursor {
+		cursor = uint64(since)
+	}
+
+	for {
+		s.mu.Lock()
+		events, _ := s.events.since(cursor)
+		changed := s.events.wait()
+		s.mu.Unlock()
+
+		for _, e := range events {
+			cursor = e.seq
+			switch e.kind {
+			case eventRemoved, eventDeviceDisconnected:
+				removed = true
+			case eventDetected:
+				if removed && e.snapshot != nil && filter.matches(e.snapshot) {
+					result := buildReadingsFromState(e.snapshot)
+					result["seq"] = e.seq
+					addRegistryFields(s.registry, result)
+					return result, nil
+				}
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-waitCtx.Done():
+			return nil, fmt.Errorf("await_scan: %w", waitCtx.Err())
+		case <-s.cancelCtx.Done():
+			return nil, fmt.Errorf("await_scan: sensor closed")
+		}
+	}
+}
+
+// handleAwaitRemoval blocks until the tag with the given uid, or the tag in
+// the field if uid is omitted, is removed, and returns its dwell time. If the
+// requested tag has already left the field, its most recent removal is
+// returned immediately.
+func (s *pn532Sensor) handleAwaitRemoval(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	waitCtx := ctx
+	if timeoutMs, ok := cmd["timeout_ms"].(float64); ok && timeoutMs > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	uid, _ := cmd["uid"].(string)
+
+	s.mu.Lock()
+	if uid == "" {
+		if !s.state.tagPresent {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("await_removal: no tag present")
+		}
+		uid = s.state.uid
+	}
+	if !s.state.tagPresent || s.state.uid != uid {
+		removed, ok := s.events.lastRemoval(uid)
+		s.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("await_removal: tag %s is not present", uid)
+		}
+		return removalResult(removed), nil
+	}
+	cursor := s.events.lastSeq
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		events, _ := s.events.since(cursor)
+		changed := s.events.wait()
+		s.mu.Unlock()
+
+		for _, e := range events {
+			switch {
+			case e.kind == eventRemoved && e.uid == uid:
+				return removalResult(e), nil
+			case e.kind == eventDeviceDisconnected:
+				return nil, fmt.Errorf("await_removal: device disconnected before tag %s was removed", uid)
+			}
+			cursor = e.seq
+		}
+
+		select {
+		case <-changed:
+		case <-waitCtx.Done():
+			return nil, fmt.Errorf("await_removal: %w", waitCtx.Err())
+		case <-s.cancelCtx.Done():
+			return nil, fmt.Errorf("await_removal: sensor closed")
+		}
+	}
+}
+
+func removalResult(e tagEvent) map[string]interface{} {
+	return map[string]interface{}{
+		"uid":        e.uid,
+		"tag_type":   e.tagType,
+		"dwell_ms":   e.dwell.Milliseconds(),
+		"removed_at": e.at.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// handleGetEvents returns logged events with a sequence number greater than
+// since. If there are none and wait_ms is set, it blocks until one arrives or
+// wait_ms elapses, in which case the event list is empty. latest_seq is the
+// cursor to pass as since on the next call; missed counts events after since
+// that were dropped from the log before they could be returned.
+func (s *pn532Sensor) handleGetEvents(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	var since uint64
+	if v, ok := cmd["since"].(float64); ok && v > 0 {
+		since = uint64(v)
+	}
+
+	var timeout <-chan time.Time
+	if waitMs, ok := cmd["wait_ms"].(float64); ok && waitMs > 0 {
+		timer := time.NewTimer(time.Duration(waitMs) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		s.mu.Lock()
+		events, missed := s.events.since(since)
+		latest := s.events.lastSeq
+		changed := s.events.wait()
+		s.mu.Unlock()
+
+		if len(events) > 0 || timeout == nil {
+			list := make([]interface{}, 0, len(events))
+			for _, e := range events {
+				list = append(list, e.toMap())
+			}
+			return map[string]interface{}{
+				"events":     list,
+				"latest_seq": latest,
+				"missed":     missed,
+			}, nil
+		}
+
+		select {
+		case <-changed:
+		case <-timeout:
+			timeout = nil
+		case <-ctx.Done():
+			return nil, fmt.Errorf("get_events: %w", ctx.Err())
+		case <-s.cancelCtx.Done():
+			return nil, fmt.Errorf("get_events: sensor closed")
+		}
+	}
+}
+
+func (s *pn532Sensor) handleWriteText(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	text, ok := cmd["text"].(string)
+	if !ok || text == "" {
+		return nil, fmt.Errorf("write_text: missing or invalid \"text\" field")
+	}
+	lang := "en"
+	if l, ok := cmd["lang"].(string); ok && l != "" {
+		lang = l
+	}
+
+	payload, err := textPayload(text, lang)
+	if err != nil {
+		return nil, fmt.Errorf("write_text: %w", err)
 	}
 
-	select {
-	case snap := <-s.scanNotify:
-		return buildReadingsFromState(&snap), nil
-	case <-waitCtx.Done():
-		return nil, fmt.Errorf("await_scan: %w", waitCtx.Err())
-	case <-s.cancelCtx.Done():
-		return nil, fmt.Errorf("await_scan: sensor closed")
+	return s.writeToNextTag(ctx, "write_text", cmd, payload)
+}
+
+func (s *pn532Sensor) handleWriteNDEF(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	specs, ok := cmd["records"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("write_ndef: missing or invalid \"records\" field")
 	}
+
+	payload, err := ndefPayloadFromSpecs(specs)
+	if err != nil {
+		return nil, fmt.Errorf("write_ndef: %w", err)
+	}
+
+	return s.writeToNextTag(ctx, "write_ndef", cmd, payload)
+}
+
+// writeToNextTag waits for the next tag via the poller's WriteToNextTag, writes
+// payload, and returns the tag's readings plus bytes_written. If the written
+// tag is the one currently cached, the cache is refreshed so Readings reflects
+// the new content without waiting for the tag to be re-presented.
+func (s *pn532Sensor) writeToNextTag(ctx context.Context, action string, cmd map[string]interface{}, payload *ndefPayload) (map[string]interface{}, error) {
+	timeout := defaultWriteTimeout
+	if timeoutMs, ok := cmd["timeout_ms"].(float64); ok && timeoutMs > 0 {
+		timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+
+	s.mu.RLock()
+	sess := s.session
+	device := s.device
+	cached := s.state
+	s.mu.RUnlock()
+
+	if sess == nil {
+		return nil, fmt.Errorf("%s: device not connected", action)
+	}
+	// WriteToNextTag relies on the polling loop to see the tag.
+	defer s.irq.hold()()
+
+	// Reject early if the tag already in the field is too small. The check is
+	// repeated against the tag actually presented before any page is written.
+	if cached.tagPresent {
+		if err := checkNDEFCapacity(payload, cached.userMemoryBytes); err != nil {
+			return nil, fmt.Errorf("%s: %w", action, err)
+		}
+	}
+
+	var written tagState
+	err := sess.WriteToNextTag(s.cancelCtx, ctx, timeout, func(writeCtx context.Context, tag pn532lib.Tag) error {
+		detectedTag := &pn532lib.DetectedTag{
+			DetectedAt: time.Now(),
+			UID:        tag.UID(),
+			UIDBytes:   tag.UIDBytes(),
+			Type:       tag.Type(),
+		}
+
+		// Protected tags need ntag_password before anything is read.
+		s.authenticateConfigured(writeCtx, device, detectedTag, true, 0)
+
+		ops := tagops.New(device)
+		if err := ops.InitFromDetectedTag(writeCtx, detectedTag); err != nil {
+			return fmt.Errorf("failed to initialize tag operations: %w", err)
+		}
+		if info, err := ops.GetTagInfo(); err == nil {
+			if err := checkNDEFCapacity(payload, info.UserMemory); err != nil {
+				return err
+			}
+		}
+		if err := writeNDEFPayload(writeCtx, ops, payload); err != nil {
+			return err
+		}
+
+		written = s.readTagInfo(writeCtx, ops, detectedTag)
+		s.readPasswordState(writeCtx, device, ops, &written)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", action, err)
+	}
+
+	s.mu.Lock()
+	if s.state.tagPresent && s.state.uid == written.uid {
+		written.detectedAt = s.state.detectedAt
+		s.state.setTag(written)
+	}
+	snapshot := s.state
+	snapshot.setTag(written)
+	s.mu.Unlock()
+
+	result := buildReadingsFromState(&snapshot)
+	result["bytes_written"] = len(payload.raw)
+	addRegistryFields(s.registry, result)
+	return result, nil
+}
+
+// handleRegisterTag adds or replaces a registry entry. uid defaults to the
+// tag currently in the field.
+func (s *pn532Sensor) handleRegisterTag(cmd map[string]interface{}) (map[string]interface{}, error) {
+	if s.registry == nil {
+		return nil, fmt.Errorf("register_tag: tag registry not available")
+	}
+	label, ok := cmd["label"].(string)
+	if !ok || label == "" {
+		return nil, fmt.Errorf("register_tag: missing or invalid \"label\" field")
+	}
+	var metadata map[string]interface{}
+	if raw, ok := cmd["metadata"]; ok {
+		if metadata, ok = raw.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("register_tag: \"metadata\" must be an object")
+		}
+	}
+
+	uid, _ := cmd["uid"].(string)
+	if uid == "" {
+		s.mu.RLock()
+		if s.state.tagPresent {
+			uid = s.state.uid
+		}
+		s.mu.RUnlock()
+		if uid == "" {
+			return nil, fmt.Errorf("register_tag: no \"uid\" given and no tag present")
+		}
+	}
+
+	tag := registeredTag{
+		UID:          strings.ToLower(uid),
+		Label:        label,
+		Metadata:     metadata,
+		RegisteredAt: time.Now(),
+	}
+	if err := s.registry.register(tag); err != nil {
+		return nil, fmt.Errorf("register_tag: %w", err)
+	}
+	return tag.toMap(), nil
+}
+
+func (s *pn532Sensor) handleUnregisterTag(cmd map[string]interface{}) (map[string]interface{}, error) {
+	if s.registry == nil {
+		return nil, fmt.Errorf("unregister_tag: tag registry not available")
+	}
+	uid, ok := cmd["uid"].(string)
+	if !ok || uid == "" {
+		return nil, fmt.Errorf("unregister_tag: missing or invalid \"uid\" field")
+	}
+	removed, err := s.registry.unregister(uid)
+	if err != nil {
+		return nil, fmt.Errorf("unregister_tag: %w", err)
+	}
+	return map[string]interface{}{"uid": strings.ToLower(uid), "removed": removed}, nil
+}
+
+func (s *pn532Sensor) handleListTags() (map[string]interface{}, error) {
+	if s.registry == nil {
+		return nil, fmt.Errorf("list_tags: tag registry not available")
+	}
+	tags := s.registry.list()
+	list := make([]interface{}, 0, len(tags))
+	for _, t := range tags {
+		list = append(list, t.toMap())
+	}
+	return map[string]interface{}{"tags": list, "count": len(list)}, nil
+}
+
+func (s *pn532Sensor) handleGetTag(cmd map[string]interface{}) (map[string]interface{}, error) {
+	uid, ok := cmd["uid"].(string)
+	if !ok || uid == "" {
+		return nil, fmt.Errorf("get_tag: missing or invalid \"uid\" field")
+	}
+	if s.registry == nil {
+		return nil, fmt.Errorf("get_tag: tag registry not available")
+	}
+	t, ok := s.registry.get(uid)
+	if !ok {
+		return nil, fmt.Errorf("get_tag: tag %s is not registered", uid)
+	}
+	return t.toMap(), nil
 }
 
 func (s *pn532Sensor) handleDiagnostics(ctx context.Context) (map[string]interface{}, error) {
@@ -58,8 +458,15 @@ func (s *pn532Sensor) handleDiagnostics(ctx context.Context) (map[string]interfa
 	if sess == nil {
 		return nil, fmt.Errorf("diagnostics: device not connected")
 	}
+	defer s.irq.hold()()
 
-	result := map[string]interface{}{}
+	detectionMode := s.irq.detectionMode()
+	if s.config().PollMode == pollModeAutopoll {
+		detectionMode = pollModeAutopoll
+	}
+	result := map[string]interface{}{
+		"detection_mode": detectionMode,
+	}
 
 	err := sess.PauseAndRun(ctx, func(dev *pn532lib.Device) error {
 		if dev == nil {
@@ -101,3 +508,46 @@ func (s *pn532Sensor) handleDiagnostics(ctx context.Context) (map[string]interfa
 
 	return result, nil
 }
+
+// intArg reads a non-negative integer field from a DoCommand request.
+func intArg(cmd map[string]interface{}, name string) (int, error) {
+	v, ok := cmd[name].(float64)
+	if !ok || v < 0 || v != float64(int(v)) {
+		return 0, fmt.Errorf("missing or invalid %q field", name)
+	}
+	return int(v), nil
+}
+
+// runOnTag pauses polling, selects the tag in the field and runs fn with it.
+// It fails if no tag is present.
+func (s *pn532Sensor) runOnTag(ctx context.Context, fn func(device *pn532lib.Device, tag *pn532lib.DetectedTag) error) error {
+	s.mu.RLock()
+	sess := s.session
+	s.mu.RUnlock()
+
+	if sess == nil {
+		return fmt.Errorf("device not connected")
+	}
+	defer s.irq.hold()()
+
+	return sess.PauseAndRun(ctx, func(device *pn532lib.Device) error {
+		if device == nil {
+			return fmt.Errorf("device not available")
+		}
+		tag, err := device.DetectTag(ctx)
+		if err != nil {
+			return fmt.Errorf("tag detection failed: %w", err)
+		}
+		if tag == nil {
+			return fmt.Errorf("no tag present")
+		}
+		return fn(device, tag)
+	})
+}
+
+// reselectTag wakes the selected tag after an error response, which leaves
+// MIFARE and NTAG tags halted or idle.
+func reselectTag(ctx context.Context, device *pn532lib.Device) error {
+	_ = device.InDeselect(ctx)
+	return device.InSelect(ctx)
+}
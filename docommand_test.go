@@ -2,8 +2,13 @@ package pn532
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/ZaparooProject/go-pn532/polling"
+	"github.com/ZaparooProject/go-pn532/tagops"
 )
 
 func TestAwaitScanReturnsCachedSnapshot(t *testing.T) {
@@ -53,6 +58,7 @@ func TestAwaitScanSnapshotIsolatedFromRemoval(t *testing.T) {
 
 	result, err := s.DoCommand(context.Background(), map[string]interface{}{
 		"action":     "await_scan",
+		"since":      float64(0),
 		"timeout_ms": float64(1000),
 	})
 	if err != nil {
@@ -119,7 +125,7 @@ func TestAwaitScanSensorClosed(t *testing.T) {
 	}
 }
 
-func TestOnCardDetectedNotifyReplaceStale(t *testing.T) {
+func TestAwaitScanSinceReplaysPastTaps(t *testing.T) {
 	readNDEF := false
 	s, mock := newTestSensorWithDevice(t, &Config{
 		Transport:  "i2c",
@@ -127,26 +133,83 @@ func TestOnCardDetectedNotifyReplaceStale(t *testing.T) {
 		ReadNDEF:   &readNDEF,
 	})
 
-	// First detection — not consumed.
+	// Two detections before anyone is waiting.
 	tagA := setupNTAG215Mock(mock)
 	tagA.UID = "04aaaaaa"
 	_ = s.onCardDetected(context.Background(), tagA)
 
-	// Second detection — should replace stale entry.
 	mock.Reset()
 	tagB := setupNTAG215Mock(mock)
 	tagB.UID = "04bbbbbb"
 	_ = s.onCardDetected(context.Background(), tagB)
 
+	// Without since, past taps are not delivered.
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "await_scan",
+		"timeout_ms": float64(50),
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("await_scan without since: err = %v, want deadline exceeded", err)
+	}
+
+	// With since, taps are replayed in order.
 	result, err := s.DoCommand(context.Background(), map[string]interface{}{
 		"action":     "await_scan",
+		"since":      float64(0),
+		"timeout_ms": float64(100),
+	})
+	if err != nil {
+		t.Fatalf("await_scan returned error: %v", err)
+	}
+	if result["uid"] != "04aaaaaa" {
+		t.Errorf("uid = %q, want 04aaaaaa", result["uid"])
+	}
+
+	result, err = s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "await_scan",
+		"since":      float64(result["seq"].(uint64)),
 		"timeout_ms": float64(100),
 	})
 	if err != nil {
 		t.Fatalf("await_scan returned error: %v", err)
 	}
 	if result["uid"] != "04bbbbbb" {
-		t.Errorf("uid = %q, want 04bbbbbb (freshest detection)", result["uid"])
+		t.Errorf("uid = %q, want 04bbbbbb", result["uid"])
+	}
+}
+
+func TestAwaitScanFanOut(t *testing.T) {
+	readNDEF := false
+	s, mock := newTestSensorWithDevice(t, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+	})
+	tag := setupNTAG215Mock(mock)
+
+	const waiters = 3
+	results := make(chan error, waiters)
+	for range waiters {
+		go func() {
+			result, err := s.DoCommand(context.Background(), map[string]interface{}{
+				"action":     "await_scan",
+				"timeout_ms": float64(2000),
+			})
+			if err == nil && result["uid"] != tag.UID {
+				err = fmt.Errorf("uid = %v, want %s", result["uid"], tag.UID)
+			}
+			results <- err
+		}()
+	}
+
+	// Let every waiter register its cursor before the tap.
+	time.Sleep(50 * time.Millisecond)
+	_ = s.onCardDetected(context.Background(), tag)
+
+	for range waiters {
+		if err := <-results; err != nil {
+			t.Errorf("waiter: %v", err)
+		}
 	}
 }
 
@@ -207,3 +270,400 @@ func TestDiagnosticsPartialResults(t *testing.T) {
 		t.Error("diagnostics result should contain at least one diagnostic key")
 	}
 }
+
+func TestWriteTextMissingText(t *testing.T) {
+	s, _ := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "write_text",
+	})
+	if err == nil {
+		t.Fatal("write_text without text should return error")
+	}
+}
+
+func TestWriteTextDisconnected(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "write_text",
+		"text":   "hello",
+	})
+	if err == nil {
+		t.Fatal("write_text should return error when session is nil")
+	}
+}
+
+func TestWriteNDEFPayloadNTAG(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	tag := setupNTAG215Mock(mock)
+	tag.UID = "04123456789abc"
+	ops := tagops.New(s.device)
+	if err := ops.InitFromDetectedTag(context.Background(), tag); err != nil {
+		t.Fatalf("InitFromDetectedTag: %v", err)
+	}
+
+	payload, err := textPayload("hallo", "de")
+	if err != nil {
+		t.Fatalf("textPayload: %v", err)
+	}
+	if payload.lib != nil {
+		t.Error("non-en Text record should not have a go-pn532 representation")
+	}
+
+	// One write ack per page, then page-by-page read-back (FAST_READ disabled).
+	pages := (len(payload.raw) + 3) / 4
+	for range pages {
+		mock.QueueResponse(0x40, []byte{0x41, 0x00})
+	}
+	for i := range pages {
+		resp := make([]byte, 18)
+		resp[0] = 0x41
+		copy(resp[2:], payload.raw[i*4:min(len(payload.raw), i*4+4)])
+		mock.QueueResponse(0x40, resp)
+	}
+	mock.SetError(0x42, errors.New("FAST_READ unsupported"))
+
+	if err := writeNDEFPayload(context.Background(), ops, payload); err != nil {
+		t.Fatalf("writeNDEFPayload: %v", err)
+	}
+}
+
+func TestWriteNDEFRejectsOversizedMessage(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.session = polling.NewSession(s.device, nil)
+	s.state.tagPresent = true
+	s.state.uid = "04aaaaaa"
+	s.state.userMemoryBytes = 16
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "write_ndef",
+		"records": []interface{}{
+			map[string]interface{}{"type": "text", "text": "this message does not fit in sixteen bytes"},
+		},
+		"timeout_ms": float64(100),
+	})
+	var tooLarge *ndefTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected ndefTooLargeError, got %v", err)
+	}
+	if tooLarge.CapacityBytes != 16 {
+		t.Errorf("CapacityBytes = %d, want 16", tooLarge.CapacityBytes)
+	}
+	if n := mock.GetCallCount(0x4A); n != 0 {
+		t.Errorf("InListPassiveTarget called %d times, want 0 (no hardware access)", n)
+	}
+}
+
+func TestWriteNDEFInvalidRecords(t *testing.T) {
+	s, _ := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":  "write_ndef",
+		"records": "not a list",
+	})
+	if err == nil {
+		t.Fatal("write_ndef with invalid records should return error")
+	}
+}
+
+func TestGetEventsRecordsTapsAndRemovals(t *testing.T) {
+	readNDEF := false
+	s, mock := newTestSensorWithDevice(t, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+	})
+	s.state.deviceHealthy = true
+
+	tag := setupNTAG215Mock(mock)
+	_ = s.onCardDetected(context.Background(), tag)
+	s.onCardRemoved()
+	s.onDeviceDisconnected(errors.New("i2c read failed"))
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "get_events",
+		"since":  float64(0),
+	})
+	if err != nil {
+		t.Fatalf("get_events returned error: %v", err)
+	}
+
+	events := result["events"].([]interface{})
+	want := []string{eventDetected, eventRemoved, eventDeviceDisconnected}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, kind := range want {
+		e := events[i].(map[string]interface{})
+		if e["event"] != kind {
+			t.Errorf("events[%d] = %v, want %s", i, e["event"], kind)
+		}
+		if e["seq"] != uint64(i+1) {
+			t.Errorf("events[%d].seq = %v, want %d", i, e["seq"], i+1)
+		}
+	}
+	if events[1].(map[string]interface{})["uid"] != tag.UID {
+		t.Error("removed event should carry the removed tag's uid")
+	}
+	if result["latest_seq"] != uint64(3) {
+		t.Errorf("latest_seq = %v, want 3", result["latest_seq"])
+	}
+}
+
+func TestGetEventsWaitsForNextEvent(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.state = tagState{deviceHealthy: true, tagPresent: true, uid: "04abcdef", tagType: "NTAG"}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.onCardRemoved()
+	}()
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":  "get_events",
+		"wait_ms": float64(2000),
+	})
+	if err != nil {
+		t.Fatalf("get_events returned error: %v", err)
+	}
+	if events := result["events"].([]interface{}); len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+}
+
+func TestGetEventsWaitTimeout(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	start := time.Now()
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":  "get_events",
+		"wait_ms": float64(50),
+	})
+	if err != nil {
+		t.Fatalf("get_events returned error: %v", err)
+	}
+	if events := result["events"].([]interface{}); len(events) != 0 {
+		t.Errorf("got %d events, want 0", len(events))
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("returned after %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestAwaitRemovalReturnsDwellTime(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.state = tagState{
+		deviceHealthy: true,
+		tagPresent:    true,
+		detectedAt:    time.Now().Add(-time.Second),
+		uid:           "04abcdef",
+		tagType:       "NTAG",
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.onCardRemoved()
+	}()
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "await_removal",
+		"timeout_ms": float64(2000),
+	})
+	if err != nil {
+		t.Fatalf("await_removal returned error: %v", err)
+	}
+	if result["uid"] != "04abcdef" {
+		t.Errorf("uid = %v, want 04abcdef", result["uid"])
+	}
+	if dwell := result["dwell_ms"].(int64); dwell < 1000 {
+		t.Errorf("dwell_ms = %d, want at least 1000", dwell)
+	}
+}
+
+func TestAwaitRemovalAlreadyRemoved(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.state = tagState{deviceHealthy: true, tagPresent: true, detectedAt: time.Now(), uid: "04abcdef", tagType: "NTAG"}
+	s.onCardRemoved()
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "await_removal",
+		"uid":    "04abcdef",
+	})
+	if err != nil {
+		t.Fatalf("await_removal returned error: %v", err)
+	}
+	if result["uid"] != "04abcdef" {
+		t.Errorf("uid = %v, want 04abcdef", result["uid"])
+	}
+
+	_, err = s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "await_removal",
+		"uid":    "04ffffff",
+	})
+	if err == nil {
+		t.Error("await_removal for an unseen uid should fail")
+	}
+}
+
+func TestAwaitRemovalNoTag(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.state.deviceHealthy = true
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "await_removal"})
+	if err == nil {
+		t.Fatal("await_removal with no tag present should fail")
+	}
+}
+
+func TestAwaitRemovalTimeout(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.state = tagState{deviceHealthy: true, tagPresent: true, detectedAt: time.Now(), uid: "04abcdef", tagType: "NTAG"}
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "await_removal",
+		"timeout_ms": float64(50),
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestAwaitScanSkipsNonMatchingTags(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.state.deviceHealthy = true
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		deliverDetection(s, "04000001")
+		time.Sleep(20 * time.Millisecond)
+		deliverDetection(s, "04000002")
+	}()
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "await_scan",
+		"uids":       []interface{}{"04000002"},
+		"timeout_ms": float64(2000),
+	})
+	if err != nil {
+		t.Fatalf("await_scan returned error: %v", err)
+	}
+	if result["uid"] != "04000002" {
+		t.Errorf("uid = %v, want 04000002", result["uid"])
+	}
+}
+
+func TestAwaitScanRequireRemovalFirst(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.state.deviceHealthy = true
+	deliverDetection(s, "04abcdef")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		// Re-reported while still in the field: must not satisfy the wait.
+		deliverDetection(s, "04abcdef")
+		s.onCardRemoved()
+		deliverDetection(s, "04abcdef")
+	}()
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":                "await_scan",
+		"require_removal_first": true,
+		"timeout_ms":            float64(2000),
+	})
+	if err != nil {
+		t.Fatalf("await_scan returned error: %v", err)
+	}
+	if result["seq"] != uint64(4) {
+		t.Errorf("seq = %v, want 4 (the detection after removal)", result["seq"])
+	}
+}
+
+// deliverDetection caches uid as the present tag and logs a detected event,
+// as onCardDetected does, without any tag I/O.
+func deliverDetection(s *pn532Sensor, uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.setTag(tagState{tagPresent: true, detectedAt: time.Now(), uid: uid, tagType: "NTAG"})
+	snapshot := s.state
+	s.events.add(tagEvent{kind: eventDetected, uid: uid, tagType: "NTAG", snapshot: &snapshot})
+}
+
+func TestAwaitScanInvalidFilter(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":          "await_scan",
+		"ndef_text_regex": "[",
+	})
+	if err == nil {
+		t.Fatal("await_scan with an invalid regex should fail")
+	}
+}
+
+func TestRegisterTagAddsLabelToReadings(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	registry, err := loadTagRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.registry = registry
+	s.state = tagState{deviceHealthy: true, tagPresent: true, uid: "04abcdef", tagType: "NTAG"}
+
+	// uid defaults to the tag in the field.
+	_, err = s.DoCommand(context.Background(), map[string]interface{}{
+		"action":   "register_tag",
+		"label":    "wrench",
+		"metadata": map[string]interface{}{"bin": "A3"},
+	})
+	if err != nil {
+		t.Fatalf("register_tag returned error: %v", err)
+	}
+
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	if readings["label"] != "wrench" {
+		t.Errorf("label = %v, want wrench", readings["label"])
+	}
+	if md, _ := readings["metadata"].(map[string]interface{}); md["bin"] != "A3" {
+		t.Errorf("metadata = %v, want bin A3", readings["metadata"])
+	}
+
+	got, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "get_tag", "uid": "04ABCDEF"})
+	if err != nil || got["label"] != "wrench" {
+		t.Errorf("get_tag = %v, %v; want wrench", got, err)
+	}
+
+	list, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "list_tags"})
+	if err != nil || list["count"] != 1 {
+		t.Errorf("list_tags = %v, %v; want one tag", list, err)
+	}
+
+	_, err = s.DoCommand(context.Background(), map[string]interface{}{"action": "unregister_tag", "uid": "04abcdef"})
+	if err != nil {
+		t.Fatalf("unregister_tag returned error: %v", err)
+	}
+	readings, _ = s.Readings(context.Background(), nil)
+	if _, ok := readings["label"]; ok {
+		t.Error("label should be absent after unregister_tag")
+	}
+}
+
+func TestRegisterTagValidation(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.registry, _ = loadTagRegistry("")
+
+	for _, cmd := range []map[string]interface{}{
+		{"action": "register_tag", "uid": "04abcdef"},
+		{"action": "register_tag", "label": "no tag present"},
+		{"action": "register_tag", "uid": "04abcdef", "label": "x", "metadata": "not an object"},
+		{"action": "get_tag", "uid": "04ffffff"},
+	} {
+		if _, err := s.DoCommand(context.Background(), cmd); err == nil {
+			t.Errorf("DoCommand(%v) should fail", cmd)
+		}
+	}
+}
@@ -0,0 +1,670 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+)
+
+// Dump envelope format, checked by restore_tag.
+const (
+	dumpFormat  = "viam-pn532-dump"
+	dumpVersion = 1
+
+	dumpUnitPage  = "page"
+	dumpUnitBlock = "block"
+)
+
+// Per-page or per-block access status in a dump.
+const (
+	dumpStatusOK = "ok"
+	// dumpStatusDenied: the tag refused the read, e.g. a password-protected
+	// NTAG page or MIFARE access bits denying every key that authenticated.
+	dumpStatusDenied = "denied"
+	// dumpStatusNoKey: no key in the key store authenticates the sector.
+	dumpStatusNoKey = "no_key"
+	// dumpStatusWriteOnly: NTAG PWD and PACK, which always read as zeros.
+	dumpStatusWriteOnly = "write_only"
+)
+
+// dumpDirName is the directory created in $VIAM_MODULE_DATA for saved dumps
+// when dump_dir is not configured.
+const dumpDirName = "dumps"
+
+// dumpEntry is one page or block of a dump. data is nil unless status is ok
+// or write_only.
+type dumpEntry struct {
+	index  int
+	data   []byte
+	status string
+}
+
+// tagDump is the raw memory of a tag with enough context to restore it.
+type tagDump struct {
+	uid      string
+	tagType  string
+	variant  string
+	dumpedAt time.Time
+	unit     string
+	// auth0 is the NTAG AUTH0, or -1 if unknown.
+	auth0  int
+	memory []dumpEntry
+}
+
+func (d *tagDump) toMap() map[string]interface{} {
+	memory := make([]interface{}, 0, len(d.memory))
+	for _, e := range d.memory {
+		memory = append(memory, map[string]interface{}{
+			"index":  e.index,
+			"data":   hex.EncodeToString(e.data),
+			"status": e.status,
+		})
+	}
+	m := map[string]interface{}{
+		"format":    dumpFormat,
+		"version":   dumpVersion,
+		"uid":       d.uid,
+		"tag_type":  d.tagType,
+		"variant":   d.variant,
+		"dumped_at": d.dumpedAt.UTC().Format(time.RFC3339),
+		"unit":      d.unit,
+		"memory":    memory,
+	}
+	if d.auth0 >= 0 {
+		m["auth0"] = d.auth0
+	}
+	return m
+}
+
+// parseTagDump decodes a dump envelope, as returned by dump_tag or read back
+// from a saved file.
+func parseTagDump(m map[string]interface{}) (*tagDump, error) {
+	if f, _ := m["format"].(string); f != dumpFormat {
+		return nil, fmt.Errorf("not a %s envelope", dumpFormat)
+	}
+	if v, _ := m["version"].(float64); int(v) != dumpVersion {
+		return nil, fmt.Errorf("unsupported dump version %v", m["version"])
+	}
+	d := &tagDump{auth0: -1}
+	d.uid, _ = m["uid"].(string)
+	d.tagType, _ = m["tag_type"].(string)
+	d.variant, _ = m["variant"].(string)
+	d.unit, _ = m["unit"].(string)
+	if d.unit != dumpUnitPage && d.unit != dumpUnitBlock {
+		return nil, fmt.Errorf("invalid unit %q", d.unit)
+	}
+	memory, ok := m["memory"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing \"memory\" list")
+	}
+	for i, raw := range memory {
+		em, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("memory[%d] is not an object", i)
+		}
+		index, err := intArg(em, "index")
+		if err != nil {
+			return nil, fmt.Errorf("memory[%d]: %w", i, err)
+		}
+		e := dumpEntry{index: index}
+		e.status, _ = em["status"].(string)
+		s, _ := em["data"].(string)
+		if e.data, err = hex.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("memory[%d]: invalid data: %w", i, err)
+		}
+		d.memory = append(d.memory, e)
+	}
+	return d, nil
+}
+
+// entry returns the readable entry for index.
+func (d *tagDump) entry(index int) ([]byte, bool) {
+	for _, e := range d.memory {
+		if e.index == index && e.status == dumpStatusOK {
+			return e.data, true
+		}
+	}
+	return nil, false
+}
+
+// dumpDir returns the configured dump directory, falling back to the module
+// data directory. It returns "" if neither is available.
+func dumpDir(cfg *Config) string {
+	if cfg.DumpDir != "" {
+		return cfg.DumpDir
+	}
+	if dir := os.Getenv("VIAM_MODULE_DATA"); dir != "" {
+		return filepath.Join(dir, dumpDirName)
+	}
+	return ""
+}
+
+// dumpFilePath resolves a dump file name inside dir. Names may not leave the
+// directory.
+func dumpFilePath(dir, name string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("no dump_dir configured and no module data directory")
+	}
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid dump file name %q", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// saveTagDump writes d to dir as <uid>_<timestamp>.json and returns its name.
+// The timestamp has millisecond resolution; a dump whose name is already
+// taken gets a -2, -3, ... suffix instead of overwriting the earlier one.
+func saveTagDump(dir string, d *tagDump) (string, error) {
+	base := fmt.Sprintf("%s_%s", strings.ToLower(d.uid), d.dumpedAt.UTC().Format("20060102T150405.000Z"))
+	if _, err := dumpFilePath(dir, base+".json"); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(d.toMap(), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode dump: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create dump directory: %w", err)
+	}
+	name, path, err := reserveDumpFile(dir, base)
+	if err != nil {
+		return "", fmt.Errorf("failed to create dump file: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0o644); err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("failed to write dump: %w", err)
+	}
+	return name, nil
+}
+
+// reserveDumpFile creates an empty file named base.json in dir, or
+// base-<n>.json for the first n that is free, so concurrent dumps never pick
+// the same name.
+func reserveDumpFile(dir, base string) (name, path string, err error) {
+	for n := 1; ; n++ {
+		name = base + ".json"
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d.json", base, n)
+		}
+		path = filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return name, path, f.Close()
+	}
+}
+
+// loadTagDump reads a saved dump.
+func loadTagDump(path string) (*tagDump, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse dump %s: %w", path, err)
+	}
+	return parseTagDump(m)
+}
+
+// isTagRefusal reports whether err is the tag (rather than the transport)
+// rejecting a command.
+func isTagRefusal(err error) bool {
+	var pnErr *pn532.PN532Error
+	return errors.As(err, &pnErr)
+}
+
+// ntagUserEnd returns the page after the last user page: the dynamic lock
+// page, or the configuration area on tags without one.
+func ntagUserEnd(layout ntagLockLayout) int {
+	if layout.dynLockPage != 0 {
+		return layout.dynLockPage
+	}
+	return layout.totalPages - ntagConfigPages
+}
+
+// dumpNTAG reads every page of an NTAG or Ultralight EV1. Pages the tag
+// refuses to read are marked denied; a four-page read that fails is retried
+// page by page so that pages below AUTH0 are still read.
+func dumpNTAG(ctx context.Context, device *pn532.Device, layout ntagLockLayout) ([]dumpEntry, error) {
+	entries := make([]dumpEntry, 0, layout.totalPages)
+	for first := 0; first < layout.totalPages; first += 4 {
+		n := min(4, layout.totalPages-first)
+		data, err := ntagReadPages(ctx, device, first)
+		if err == nil {
+			for i := 0; i < n; i++ {
+				entries = append(entries, dumpEntry{index: first + i, data: data[i*ntagPageSize : (i+1)*ntagPageSize], status: dumpStatusOK})
+			}
+			continue
+		}
+		if !isTagRefusal(err) {
+			return nil, err
+		}
+		_ = reselectTag(ctx, device)
+		for page := first; page < first+n; page++ {
+			data, err := ntagReadPages(ctx, device, page)
+			switch {
+			case err == nil:
+				entries = append(entries, dumpEntry{index: page, data: data[:ntagPageSize], status: dumpStatusOK})
+			case isTagRefusal(err):
+				_ = reselectTag(ctx, device)
+				entries = append(entries, dumpEntry{index: page, status: dumpStatusDenied})
+			default:
+				return nil, err
+			}
+		}
+	}
+	// PWD and PACK are the last two configuration pages.
+	for i := layout.totalPages - 2; i < layout.totalPages; i++ {
+		if entries[i].status == dumpStatusOK {
+			entries[i].status = dumpStatusWriteOnly
+		}
+	}
+	return entries, nil
+}
+
+// dumpMIFARE reads every block of a MIFARE Classic, sector by sector, with
+// the key store.
+func (s *pn532Sensor) dumpMIFARE(ctx context.Context, m *mifareSession) ([]dumpEntry, error) {
+	var entries []dumpEntry
+	for sector := 0; sector < mifareSectorCount(m.tag.SAK); sector++ {
+		first, count := mifareSectorBlocks(sector)
+		var blocks [][]byte
+		_, err := m.withSectorKey(ctx, s.keys, sector, func() error {
+			blocks = blocks[:0]
+			for b := first; b < first+count; b++ {
+				data, err := m.readBlock(ctx, b)
+				if err != nil {
+					return err
+				}
+				blocks = append(blocks, data)
+			}
+			return nil
+		})
+		status := dumpStatusOK
+		switch {
+		case err == nil:
+		case errors.Is(err, errNoMIFAREKey):
+			status = dumpStatusNoKey
+		case isTagRefusal(err):
+			status = dumpStatusDenied
+		default:
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			e := dumpEntry{index: first + i, status: status}
+			if status == dumpStatusOK {
+				e.data = blocks[i]
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// handleDumpTag reads the raw memory of the NTAG, Ultralight EV1 or MIFARE
+// Classic in the field. With save, the dump is also written to dump_dir.
+func (s *pn532Sensor) handleDumpTag(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	save, _ := cmd["save"].(bool)
+	dir := dumpDir(s.config())
+	if save && dir == "" {
+		return nil, fmt.Errorf("dump_tag: no dump_dir configured and no module data directory")
+	}
+
+	var dump *tagDump
+	err := s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		dump = &tagDump{
+			uid:      tag.UID,
+			tagType:  string(tag.Type),
+			dumpedAt: time.Now(),
+			auth0:    -1,
+		}
+		switch tag.Type {
+		case pn532.TagTypeNTAG:
+			layout, err := ntagLockLayoutFor(ctx, device)
+			if err != nil {
+				return err
+			}
+			s.authenticateConfigured(ctx, device, tag, false, layout.totalPages-1)
+			entries, err := dumpNTAG(ctx, device, layout)
+			if err != nil {
+				return err
+			}
+			dump.variant = layout.variant
+			dump.unit = dumpUnitPage
+			dump.memory = entries
+			// AUTH0 is byte 3 of CFG0, the first configuration page.
+			if cfg0 := entries[layout.totalPages-ntagConfigPages]; cfg0.status == dumpStatusOK {
+				dump.auth0 = int(cfg0.data[3])
+			}
+		case pn532.TagTypeMIFARE:
+			m, err := newMIFARESession(device, tag)
+			if err != nil {
+				return err
+			}
+			entries, err := s.dumpMIFARE(ctx, m)
+			if err != nil {
+				return err
+			}
+			dump.variant = mifareVariantName(tag.SAK)
+			dump.unit = dumpUnitBlock
+			dump.memory = entries
+		default:
+			return fmt.Errorf("tag %s is %s, not NTAG, Ultralight or MIFARE Classic", tag.UID, tag.Type)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dump_tag: %w", err)
+	}
+
+	result := dump.toMap()
+	if save {
+		name, err := saveTagDump(dir, dump)
+		if err != nil {
+			return nil, fmt.Errorf("dump_tag: %w", err)
+		}
+		result["file"] = name
+	}
+	return result, nil
+}
+
+// mifareVariantName names a MIFARE Classic model by its SAK.
+func mifareVariantName(sak byte) string {
+	switch mifareSectorCount(sak) {
+	case 5:
+		return "MIFARE Mini"
+	case 40:
+		return "MIFARE Classic 4K"
+	default:
+		return "MIFARE Classic 1K"
+	}
+}
+
+// isBlankUserArea reports whether data holds nothing but NULL, Lock Control
+// and Memory Control TLVs, an optional empty NDEF TLV (03 00) and its
+// terminator, followed by zeros. Factory-blank NTAG21x tags start with a Lock
+// Control TLV (01 03 A0 0C 34) before the empty NDEF TLV.
+func isBlankUserArea(data []byte) bool {
+	rest := data
+	for len(rest) > 0 && rest[0] <= 0x02 {
+		if rest[0] == 0x00 {
+			rest = rest[1:]
+			continue
+		}
+		if len(rest) < 2 {
+			return false
+		}
+		n := 2 + int(rest[1])
+		if rest[1] == 0xFF {
+			if len(rest) < 4 {
+				return false
+			}
+			n = 4 + int(rest[2])<<8 + int(rest[3])
+		}
+		if len(rest) < n {
+			return false
+		}
+		rest = rest[n:]
+	}
+	if bytes.HasPrefix(rest, []byte{0x03, 0x00, 0xFE}) {
+		rest = rest[3:]
+	} else if bytes.HasPrefix(rest, []byte{0x03, 0x00}) {
+		rest = rest[2:]
+	}
+	for _, b := range rest {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreNTAG writes the user pages of d to the NTAG in the field and reads
+// them back. The tag must be the same model, have no lock bits set, and be
+// blank unless overwrite is set.
+func restoreNTAG(ctx context.Context, device *pn532.Device, d *tagDump, overwrite bool) (written, skipped int, err error) {
+	layout, err := ntagLockLayoutFor(ctx, device)
+	if err != nil {
+		return 0, 0, err
+	}
+	if layout.variant != d.variant {
+		return 0, 0, fmt.Errorf("dump is of a %s, tag is a %s", d.variant, layout.variant)
+	}
+	locked, err := ntagLockedBits(ctx, device, layout)
+	if err != nil {
+		return 0, 0, err
+	}
+	if locked != "" {
+		return 0, 0, fmt.Errorf("tag has %s bits set", locked)
+	}
+
+	end := ntagUserEnd(layout)
+	current, err := ntagReadRange(ctx, device, ntagUserStartPage, end)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !overwrite && !isBlankUserArea(current) {
+		return 0, 0, fmt.Errorf("tag is not blank; pass \"overwrite\": true to replace its content")
+	}
+
+	want := bytes.Clone(current)
+	for page := ntagUserStartPage; page < end; page++ {
+		data, ok := d.entry(page)
+		if !ok || len(data) != ntagPageSize {
+			skipped++
+			continue
+		}
+		if err := ntagWritePage(ctx, device, page, data); err != nil {
+			return written, skipped, err
+		}
+		copy(want[(page-ntagUserStartPage)*ntagPageSize:], data)
+		written++
+	}
+
+	got, err := ntagReadRange(ctx, device, ntagUserStartPage, end)
+	if err != nil {
+		return written, skipped, fmt.Errorf("verification failed: %w", err)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			page := ntagUserStartPage + i/ntagPageSize
+			return written, skipped, fmt.Errorf("verification failed: page %d reads %X", page, got[(page-ntagUserStartPage)*ntagPageSize:][:ntagPageSize])
+		}
+	}
+	return written, skipped, nil
+}
+
+// ntagLockedBits names the first lock field with any bit set, or "" if the
+// tag is fully unlocked.
+func ntagLockedBits(ctx context.Context, device *pn532.Device, layout ntagLockLayout) (string, error) {
+	header, err := ntagReadPages(ctx, device, ntagStaticLockPage)
+	if err != nil {
+		return "", err
+	}
+	if header[2] != 0 || header[3] != 0 {
+		return "static lock", nil
+	}
+	if layout.dynLockPage == 0 {
+		return "", nil
+	}
+	dyn, err := ntagReadPages(ctx, device, layout.dynLockPage)
+	if err != nil {
+		return "", err
+	}
+	for i, mask := range layout.dynLock {
+		if dyn[i]&mask != 0 {
+			return "dynamic lock", nil
+		}
+	}
+	return "", nil
+}
+
+// ntagReadRange reads pages [first, end).
+func ntagReadRange(ctx context.Context, device *pn532.Device, first, end int) ([]byte, error) {
+	out := make([]byte, 0, (end-first)*ntagPageSize)
+	for page := first; page < end; page += 4 {
+		data, err := ntagReadPages(ctx, device, page)
+		if err != nil {
+			return nil, err
+		}
+		n := min(4, end-page)
+		out = append(out, data[:n*ntagPageSize]...)
+	}
+	return out, nil
+}
+
+// restoreMIFARE writes the data blocks of d (never block 0 or sector
+// trailers) to the MIFARE Classic in the field, verifying each block. The
+// tag must have the same number of sectors and, unless overwrite is set,
+// only zeros in its data blocks.
+func (s *pn532Sensor) restoreMIFARE(ctx context.Context, m *mifareSession, d *tagDump, overwrite bool) (written, skipped int, err error) {
+	if v := mifareVariantName(m.tag.SAK); v != d.variant {
+		return 0, 0, fmt.Errorf("dump is of a %s, tag is a %s", d.variant, v)
+	}
+	sectors := mifareSectorCount(m.tag.SAK)
+	dataBlocks := func(sector int) []int {
+		first, count := mifareSectorBlocks(sector)
+		var blocks []int
+		for b := first; b < first+count-1; b++ {
+			if b != mifareManufacturerBlk {
+				blocks = append(blocks, b)
+			}
+		}
+		return blocks
+	}
+
+	if !overwrite {
+		zero := make([]byte, mifareBlockSize)
+		for sector := 0; sector < sectors; sector++ {
+			_, err := m.withSectorKey(ctx, s.keys, sector, func() error {
+				for _, b := range dataBlocks(sector) {
+					data, err := m.readBlock(ctx, b)
+					if err != nil {
+						return err
+					}
+					if !bytes.Equal(data, zero) {
+						return fmt.Errorf("tag is not blank (block %d); pass \"overwrite\": true to replace its content", b)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	for sector := 0; sector < sectors; sector++ {
+		var pending []int
+		for _, b := range dataBlocks(sector) {
+			if data, ok := d.entry(b); ok && len(data) == mifareBlockSize {
+				pending = append(pending, b)
+			} else {
+				skipped++
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		_, err := m.withSectorKey(ctx, s.keys, sector, func() error {
+			for _, b := range pending {
+				data, _ := d.entry(b)
+				if err := m.writeBlock(ctx, b, data); err != nil {
+					return err
+				}
+				got, err := m.readBlock(ctx, b)
+				if err != nil {
+					return fmt.Errorf("verification failed: %w", err)
+				}
+				if !bytes.Equal(got, data) {
+					return fmt.Errorf("verification failed: block %d reads %X", b, got)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return written, skipped, err
+		}
+		written += len(pending)
+	}
+	return written, skipped, nil
+}
+
+// handleRestoreTag writes the user area of a dump, given inline as dump or
+// by file name in dump_dir, to the tag in the field.
+func (s *pn532Sensor) handleRestoreTag(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	var d *tagDump
+	var err error
+	switch {
+	case cmd["dump"] != nil:
+		m, ok := cmd["dump"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("restore_tag: \"dump\" must be an object")
+		}
+		d, err = parseTagDump(m)
+	case cmd["file"] != nil:
+		name, _ := cmd["file"].(string)
+		var path string
+		if path, err = dumpFilePath(dumpDir(s.config()), name); err == nil {
+			d, err = loadTagDump(path)
+		}
+	default:
+		return nil, fmt.Errorf("restore_tag: missing \"dump\" or \"file\" field")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restore_tag: %w", err)
+	}
+	overwrite, _ := cmd["overwrite"].(bool)
+
+	var result map[string]interface{}
+	err = s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		if string(tag.Type) != d.tagType {
+			return fmt.Errorf("dump is of a %s tag, tag %s is %s", d.tagType, tag.UID, tag.Type)
+		}
+		var written, skipped int
+		var err error
+		switch tag.Type {
+		case pn532.TagTypeNTAG:
+			s.authenticateConfigured(ctx, device, tag, true, 0)
+			written, skipped, err = restoreNTAG(ctx, device, d, overwrite)
+		case pn532.TagTypeMIFARE:
+			var m *mifareSession
+			if m, err = newMIFARESession(device, tag); err == nil {
+				written, skipped, err = s.restoreMIFARE(ctx, m, d, overwrite)
+			}
+		default:
+			err = fmt.Errorf("tag %s is %s, not NTAG, Ultralight or MIFARE Classic", tag.UID, tag.Type)
+		}
+		if err != nil {
+			return err
+		}
+		result = map[string]interface{}{
+			"uid":        tag.UID,
+			"source_uid": d.uid,
+			"variant":    d.variant,
+			"written":    written,
+			"skipped":    skipped,
+			"verified":   true,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore_tag: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,232 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ntag213Version is a GET_VERSION reply through InCommunicateThru.
+var ntag213Version = []byte{0x43, 0x00, 0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x0F, 0x03}
+
+// ntag213Pages is the total page count of an NTAG213; user pages are 4-39.
+const ntag213Pages = 45
+
+func TestDumpTagNTAG(t *testing.T) {
+	dir := t.TempDir()
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", DumpDir: dir})
+	mock.QueueResponse(0x42, ntag213Version)
+	// Pages 0-39 read normally; the read at 40 fails and page 40 is
+	// readable on its own while the configuration pages are protected.
+	for page := 0; page < 40; page += 4 {
+		mock.QueueResponse(0x40, ntagPagesResponse([]byte{byte(page), 0, 0, 0}))
+	}
+	mock.QueueResponse(0x40, []byte{0x41, 0x01})
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0xAA, 0x00, 0x00, 0xBD}))
+	for i := 0; i < 3; i++ {
+		mock.QueueResponse(0x40, []byte{0x41, 0x01})
+	}
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x04, 0x00, 0x00, 0x10}))
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "dump_tag", "save": true})
+	if err != nil {
+		t.Fatalf("dump_tag: %v", err)
+	}
+	if result["variant"] != "NTAG213" || result["unit"] != dumpUnitPage || result["uid"] != "04123456789abc" {
+		t.Errorf("variant, unit, uid = %v, %v, %v", result["variant"], result["unit"], result["uid"])
+	}
+	memory := result["memory"].([]interface{})
+	if len(memory) != ntag213Pages {
+		t.Fatalf("len(memory) = %d, want %d", len(memory), ntag213Pages)
+	}
+	wantStatus := map[int]string{0: dumpStatusOK, 39: dumpStatusOK, 40: dumpStatusOK, 41: dumpStatusDenied, 43: dumpStatusDenied, 44: dumpStatusWriteOnly}
+	for page, want := range wantStatus {
+		if got := memory[page].(map[string]interface{})["status"]; got != want {
+			t.Errorf("page %d status = %v, want %s", page, got, want)
+		}
+	}
+	if got := memory[40].(map[string]interface{})["data"]; got != "aa0000bd" {
+		t.Errorf("page 40 data = %v, want aa0000bd", got)
+	}
+
+	name, _ := result["file"].(string)
+	d, err := loadTagDump(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("loadTagDump: %v", err)
+	}
+	if d.variant != "NTAG213" || len(d.memory) != ntag213Pages {
+		t.Errorf("saved dump variant, pages = %s, %d", d.variant, len(d.memory))
+	}
+}
+
+// ntag213UserDump is an NTAG213 dump whose user pages hold their own number.
+func ntag213UserDump() *tagDump {
+	d := &tagDump{uid: "04aabbccddeeff", tagType: "NTAG", variant: "NTAG213", unit: dumpUnitPage, auth0: -1}
+	for page := 0; page < ntag213Pages; page++ {
+		d.memory = append(d.memory, dumpEntry{index: page, data: []byte{byte(page), 0x11, 0x22, 0x33}, status: dumpStatusOK})
+	}
+	return d
+}
+
+// wireMap round-trips m through JSON, as a DoCommand argument arrives with
+// numbers as float64.
+func wireMap(t *testing.T, m map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestRestoreTagNTAG(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	dump := ntag213UserDump()
+
+	mock.QueueResponse(0x42, ntag213Version)
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x00, 0x00, 0x00, 0x00}, []byte{0xE1, 0x10, 0x12, 0x00}))
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x00, 0x00, 0x00, 0xBD}))
+	// The target is a factory-blank NTAG213: a Lock Control TLV, then an
+	// empty NDEF TLV.
+	for page := 4; page < 40; page += 4 {
+		if page == 4 {
+			mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x01, 0x03, 0xA0, 0x0C}, []byte{0x34, 0x03, 0x00, 0xFE}))
+		} else {
+			mock.QueueResponse(0x40, ntagPagesResponse())
+		}
+	}
+	for page := 4; page < 40; page++ {
+		mock.QueueResponse(0x40, ntagAck)
+	}
+	for page := 4; page < 40; page += 4 {
+		var pages [][]byte
+		for p := page; p < page+4; p++ {
+			data, _ := dump.entry(p)
+			pages = append(pages, data)
+		}
+		mock.QueueResponse(0x40, ntagPagesResponse(pages...))
+	}
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "restore_tag", "dump": wireMap(t, dump.toMap())})
+	if err != nil {
+		t.Fatalf("restore_tag: %v", err)
+	}
+	if result["written"] != 36 || result["skipped"] != 0 || result["verified"] != true {
+		t.Errorf("result = %v", result)
+	}
+	if result["source_uid"] != "04aabbccddeeff" || result["uid"] != "04123456789abc" {
+		t.Errorf("source_uid, uid = %v, %v", result["source_uid"], result["uid"])
+	}
+}
+
+func TestRestoreTagNTAGNotBlank(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag213Version)
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x00, 0x00, 0x00, 0x00}, []byte{0xE1, 0x10, 0x12, 0x00}))
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x00, 0x00, 0x00, 0xBD}))
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x03, 0x05, 0xD1, 0x01}))
+	mock.SetResponse(0x40, ntagPagesResponse())
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "restore_tag", "dump": wireMap(t, ntag213UserDump().toMap())})
+	if err == nil || !strings.Contains(err.Error(), "not blank") {
+		t.Fatalf("expected not blank error, got %v", err)
+	}
+	// Lock bytes, dynamic lock and the nine user-area reads; nothing written.
+	if got := mock.GetCallCount(0x40); got != 11 {
+		t.Errorf("InDataExchange calls = %d, want 11", got)
+	}
+}
+
+func TestRestoreTagVariantMismatch(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag215Version)
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "restore_tag", "dump": wireMap(t, ntag213UserDump().toMap())})
+	if err == nil || !strings.Contains(err.Error(), "NTAG213") {
+		t.Fatalf("expected variant mismatch, got %v", err)
+	}
+}
+
+func TestTagDumpFiles(t *testing.T) {
+	dir := t.TempDir()
+	d := ntag213UserDump()
+	name, err := saveTagDump(dir, d)
+	if err != nil {
+		t.Fatalf("saveTagDump: %v", err)
+	}
+	path, err := dumpFilePath(dir, name)
+	if err != nil {
+		t.Fatalf("dumpFilePath: %v", err)
+	}
+	loaded, err := loadTagDump(path)
+	if err != nil {
+		t.Fatalf("loadTagDump: %v", err)
+	}
+	got, _ := loaded.entry(17)
+	want, _ := d.entry(17)
+	if !bytes.Equal(got, want) {
+		t.Errorf("page 17 = %s, want %s", hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+
+	// A second dump of the same tag at the same time gets its own file.
+	again, err := saveTagDump(dir, d)
+	if err != nil {
+		t.Fatalf("saveTagDump: %v", err)
+	}
+	if again == name || again != strings.TrimSuffix(name, ".json")+"-2.json" {
+		t.Errorf("second dump saved as %q, first as %q", again, name)
+	}
+	if _, err := loadTagDump(filepath.Join(dir, name)); err != nil {
+		t.Errorf("first dump lost: %v", err)
+	}
+
+	for _, bad := range []string{"", "..", "../x.json", "sub/x.json"} {
+		if _, err := dumpFilePath(dir, bad); err == nil {
+			t.Errorf("dumpFilePath(%q) should fail", bad)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{"format":"other"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadTagDump(filepath.Join(dir, "bad.json")); err == nil {
+		t.Error("loading a foreign envelope should fail")
+	}
+}
+
+func TestIsBlankUserArea(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"00000000", true},
+		{"0300fe00", true},
+		{"03000000", true},
+		{"0305d101", false},
+		{"000000000001", false},
+		{"0103a00c340300fe00", true},
+		{"0103a00c340305d101", false},
+		{"0103a00c", false},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.data)
+		if got := isBlankUserArea(data); got != tt.want {
+			t.Errorf("isBlankUserArea(%s) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+
+	// User pages 4-39 of a factory-blank NTAG213.
+	ntag213 := make([]byte, 144)
+	copy(ntag213, []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x00, 0xFE})
+	if !isBlankUserArea(ntag213) {
+		t.Error("factory-blank NTAG213 user area should be blank")
+	}
+}
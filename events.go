@@ -0,0 +1,148 @@
+package pn532
+
+import "time"
+
+// eventLogCapacity is the number of events retained for get_events. Older
+// events are dropped and reported to callers as missed.
+const eventLogCapacity = 256
+
+const (
+	eventDetected           = "detected"
+	eventRemoved            = "removed"
+	eventDeviceDisconnected = "device_disconnected"
+	eventDeviceReconnected  = "device_reconnected"
+)
+
+// tagEvent is one entry in the event log. uid and tagType are set for
+// detected and removed events, snapshot for detected, dwell for removed, and
+// err for device_disconnected.
+type tagEvent struct {
+	seq      uint64
+	kind     string
+	at       time.Time
+	uid      string
+	tagType  string
+	snapshot *tagState
+	dwell    time.Duration
+	err      string
+}
+
+// eventLog is a bounded, ordered log of tagEvents with monotonic sequence
+// numbers starting at 1. It is guarded by s.mu; the zero value is ready to use.
+type eventLog struct {
+	events  []tagEvent
+	lastSeq uint64
+	// changed is closed and replaced on every add, waking get_events waiters.
+	changed chan struct{}
+}
+
+// add stamps e with the next sequence number and the current time, appends it,
+// and drops the oldest event once the log is full.
+func (l *eventLog) add(e tagEvent) {
+	l.lastSeq++
+	e.seq = l.lastSeq
+	e.at = time.Now()
+
+	if len(l.events) == eventLogCapacity {
+		copy(l.events, l.events[1:])
+		l.events[len(l.events)-1] = e
+	} else {
+		l.events = append(l.events, e)
+	}
+
+	if l.changed != nil {
+		close(l.changed)
+		l.changed = nil
+	}
+}
+
+// wait returns a channel that is closed on the next add.
+func (l *eventLog) wait() <-chan struct{} {
+	if l.changed == nil {
+		l.changed = make(chan struct{})
+	}
+	return l.changed
+}
+
+// since returns a copy of the events after seq and the number of events after
+// seq that have already been dropped. A seq ahead of lastSeq (e.g. a cursor
+// from before a module restart) is treated as 0.
+func (l *eventLog) since(seq uint64) (events []tagEvent, missed uint64) {
+	if seq > l.lastSeq {
+		seq = 0
+	}
+	if len(l.events) > 0 && l.events[0].seq > seq+1 {
+		missed = l.events[0].seq - seq - 1
+	}
+	for _, e := range l.events {
+		if e.seq > seq {
+			events = append(events, e)
+		}
+	}
+	return events, missed
+}
+
+// last returns the most recent event of the given kind.
+func (l *eventLog) last(kind string) (tagEvent, bool) {
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if l.events[i].kind == kind {
+			return l.events[i], true
+		}
+	}
+	return tagEvent{}, false
+}
+
+// firstAfter returns the earliest event after seq whose kind is one of kinds.
+func (l *eventLog) firstAfter(seq uint64, kinds ...string) (tagEvent, bool) {
+	for _, e := range l.events {
+		if e.seq <= seq {
+			continue
+		}
+		for _, k := range kinds {
+			if e.kind == k {
+				return e, true
+			}
+		}
+	}
+	return tagEvent{}, false
+}
+
+// lastRemoval returns the most recent removed event for uid, provided the tag
+// has not been detected again since.
+func (l *eventLog) lastRemoval(uid string) (tagEvent, bool) {
+	for i := len(l.events) - 1; i >= 0; i-- {
+		e := l.events[i]
+		if e.uid != uid {
+			continue
+		}
+		return e, e.kind == eventRemoved
+	}
+	return tagEvent{}, false
+}
+
+func (e tagEvent) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"seq":       e.seq,
+		"event":     e.kind,
+		"timestamp": e.at.UTC().Format(time.RFC3339Nano),
+	}
+	if e.uid != "" {
+		m["uid"] = e.uid
+		m["tag_type"] = e.tagType
+	}
+	if e.kind == eventRemoved {
+		m["dwell_ms"] = e.dwell.Milliseconds()
+	}
+	if e.err != "" {
+		m["error"] = e.err
+	}
+	return m
+}
+
+// errorString returns err's message, or "" for a nil error.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
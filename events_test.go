@@ -0,0 +1,57 @@
+package pn532
+
+import "testing"
+
+func TestEventLogSequenceAndSince(t *testing.T) {
+	var l eventLog
+	l.add(tagEvent{kind: eventDetected, uid: "a"})
+	l.add(tagEvent{kind: eventRemoved, uid: "a"})
+	l.add(tagEvent{kind: eventDetected, uid: "b"})
+
+	events, missed := l.since(1)
+	if missed != 0 {
+		t.Errorf("missed = %d, want 0", missed)
+	}
+	if len(events) != 2 || events[0].seq != 2 || events[1].seq != 3 {
+		t.Fatalf("since(1) = %+v, want seqs 2 and 3", events)
+	}
+
+	if events, _ := l.since(3); len(events) != 0 {
+		t.Errorf("since(latest) returned %d events, want 0", len(events))
+	}
+
+	// A cursor from a previous module instance starts over.
+	if events, _ := l.since(100); len(events) != 3 {
+		t.Errorf("since(ahead) returned %d events, want 3", len(events))
+	}
+}
+
+func TestEventLogDropsOldest(t *testing.T) {
+	var l eventLog
+	for range eventLogCapacity + 10 {
+		l.add(tagEvent{kind: eventDetected})
+	}
+
+	events, missed := l.since(0)
+	if len(events) != eventLogCapacity {
+		t.Errorf("retained %d events, want %d", len(events), eventLogCapacity)
+	}
+	if missed != 10 {
+		t.Errorf("missed = %d, want 10", missed)
+	}
+	if events[0].seq != 11 {
+		t.Errorf("oldest seq = %d, want 11", events[0].seq)
+	}
+}
+
+func TestEventLogWaitClosedOnAdd(t *testing.T) {
+	var l eventLog
+	ch := l.wait()
+	l.add(tagEvent{kind: eventDeviceReconnected})
+
+	select {
+	case <-ch:
+	default:
+		t.Fatal("wait channel should be closed after add")
+	}
+}
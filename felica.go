@@ -0,0 +1,208 @@
+package pn532
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+)
+
+// FeliCa (JIS X 6319-4) command and response codes.
+const (
+	felicaCmdReadWithoutEncryption = 0x06
+	felicaCmdRequestSystemCode     = 0x0C
+)
+
+const (
+	felicaIDmLength = 8
+	felicaBlockSize = 16
+
+	// felicaSystemCodeNDEF is the NFC Forum Type 3 Tag system, present on
+	// NDEF-formatted FeliCa Lite-S and Standard cards.
+	felicaSystemCodeNDEF = 0x12FC
+	// felicaServiceNDEFRead is the read-only NDEF service of a Type 3 Tag.
+	felicaServiceNDEFRead = 0x000B
+	// felicaMaxReadBlocks is the most blocks a FeliCa Lite-S returns for a
+	// single Read Without Encryption.
+	felicaMaxReadBlocks = 4
+)
+
+// felicaInfo holds the identifiers a FeliCa target returns when polled.
+type felicaInfo struct {
+	idm []byte
+	pmm []byte
+}
+
+// felicaFromDetected extracts IDm and PMm from a FeliCa detection. TargetData
+// is the polling response, starting with response code 0x01.
+func felicaFromDetected(detectedTag *pn532.DetectedTag) (felicaInfo, error) {
+	data := detectedTag.TargetData
+	if len(data) < 17 || data[0] != 0x01 {
+		return felicaInfo{}, fmt.Errorf("invalid FeliCa polling response %X", data)
+	}
+	return felicaInfo{
+		idm: slices.Clone(data[1:9]),
+		pmm: slices.Clone(data[9:17]),
+	}, nil
+}
+
+// felicaCommand sends a FeliCa command frame to the selected target and
+// returns the response without its length byte. Unlike ISO14443 targets, the
+// PN532 passes FeliCa frames through verbatim, so the length byte is added
+// and stripped here.
+func felicaCommand(ctx context.Context, device *pn532.Device, cmd []byte) ([]byte, error) {
+	frame := append([]byte{byte(len(cmd) + 1)}, cmd...)
+	res, err := device.SendDataExchange(ctx, frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) < 2 || int(res[0]) != len(res) {
+		return nil, fmt.Errorf("malformed FeliCa response %X", res)
+	}
+	if res[1] != cmd[0]+1 {
+		return nil, fmt.Errorf("unexpected FeliCa response code 0x%02X to command 0x%02X", res[1], cmd[0])
+	}
+	return res[1:], nil
+}
+
+// felicaRequestSystemCodes lists the systems on the card identified by idm.
+func felicaRequestSystemCodes(ctx context.Context, device *pn532.Device, idm []byte) ([]uint16, error) {
+	res, err := felicaCommand(ctx, device, append([]byte{felicaCmdRequestSystemCode}, idm...))
+	if err != nil {
+		return nil, fmt.Errorf("request system code failed: %w", err)
+	}
+	// Response code, IDm, number of systems, system codes (big endian).
+	if len(res) < 1+felicaIDmLength+1 {
+		return nil, fmt.Errorf("request system code response too short: %d bytes", len(res))
+	}
+	n := int(res[1+felicaIDmLength])
+	codes := res[1+felicaIDmLength+1:]
+	if len(codes) < 2*n {
+		return nil, fmt.Errorf("request system code response truncated: %d systems, %d bytes", n, len(codes))
+	}
+	out := make([]uint16, n)
+	for i := range out {
+		out[i] = uint16(codes[2*i])<<8 | uint16(codes[2*i+1])
+	}
+	return out, nil
+}
+
+// felicaReadBlocks reads count blocks of service starting at block first.
+func felicaReadBlocks(ctx context.Context, device *pn532.Device, idm []byte, service uint16, first, count int) ([]byte, error) {
+	out := make([]byte, 0, count*felicaBlockSize)
+	for start := first; start < first+count; start += felicaMaxReadBlocks {
+		n := min(felicaMaxReadBlocks, first+count-start)
+
+		cmd := append([]byte{felicaCmdReadWithoutEncryption}, idm...)
+		cmd = append(cmd, 0x01, byte(service), byte(service>>8), byte(n))
+		for b := start; b < start+n; b++ {
+			// Three-byte block list element: 16-bit block number.
+			cmd = append(cmd, 0x00, byte(b), byte(b>>8))
+		}
+
+		res, err := felicaCommand(ctx, device, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("read without encryption failed: %w", err)
+		}
+		// Response code, IDm, status flag 1, status flag 2, number of
+		// blocks, block data.
+		if len(res) < 1+felicaIDmLength+2 {
+			return nil, fmt.Errorf("read response too short: %d bytes", len(res))
+		}
+		status1, status2 := res[1+felicaIDmLength], res[2+felicaIDmLength]
+		if status1 != 0x00 {
+			return nil, fmt.Errorf("read of block %d failed with status %02X%02X", start, status1, status2)
+		}
+		data := res[3+felicaIDmLength:]
+		if len(data) < 1+n*felicaBlockSize {
+			return nil, fmt.Errorf("read of block %d returned %d bytes, want %d", start, len(data), 1+n*felicaBlockSize)
+		}
+		out = append(out, data[1:1+n*felicaBlockSize]...)
+	}
+	return out, nil
+}
+
+// readFeliCaNDEF reads the NDEF message of a Type 3 Tag: block 0 of the NDEF
+// service is the Attribute Information Block, and the message follows from
+// block 1.
+func readFeliCaNDEF(ctx context.Context, device *pn532.Device, idm []byte) ([]*ndef.Record, error) {
+	aib, err := felicaReadBlocks(ctx, device, idm, felicaServiceNDEFRead, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attribute information block: %w", err)
+	}
+
+	var sum uint16
+	for _, b := range aib[:14] {
+		sum += uint16(b)
+	}
+	if sum != uint16(aib[14])<<8|uint16(aib[15]) {
+		return nil, fmt.Errorf("attribute information block checksum mismatch")
+	}
+	if aib[0]>>4 != 1 {
+		return nil, fmt.Errorf("unsupported Type 3 Tag mapping version 0x%02X", aib[0])
+	}
+
+	length := int(aib[11])<<16 | int(aib[12])<<8 | int(aib[13])
+	if length == 0 {
+		return nil, nil
+	}
+	nmaxb := int(aib[3])<<8 | int(aib[4])
+	blocks := (length + felicaBlockSize - 1) / felicaBlockSize
+	if blocks > nmaxb {
+		return nil, fmt.Errorf("NDEF length %d exceeds tag memory", length)
+	}
+
+	data, err := felicaReadBlocks(ctx, device, idm, felicaServiceNDEFRead, 1, blocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NDEF message: %w", err)
+	}
+
+	msg := &ndef.Message{}
+	if _, err := msg.Unmarshal(data[:length]); err != nil {
+		return nil, fmt.Errorf("failed to parse NDEF message: %w", err)
+	}
+	return msg.Records, nil
+}
+
+// readFeliCaInfo fills the FeliCa fields of info and, for cards with an NDEF
+// system, its NDEF records. The caller must have exclusive device access.
+func (s *pn532Sensor) readFeliCaInfo(ctx context.Context, device *pn532.Device, detectedTag *pn532.DetectedTag, info *tagState) {
+	fi, err := felicaFromDetected(detectedTag)
+	if err != nil {
+		s.logger.Warnw("failed to parse FeliCa detection", "uid", detectedTag.UID, "error", err)
+		return
+	}
+	info.felicaIDm = hex.EncodeToString(fi.idm)
+	info.felicaPMm = hex.EncodeToString(fi.pmm)
+
+	codes, err := felicaRequestSystemCodes(ctx, device, fi.idm)
+	if err != nil {
+		s.logger.Warnw("failed to read FeliCa system codes", "uid", detectedTag.UID, "error", err)
+		return
+	}
+	info.felicaSystemCodes = codes
+
+	if readNDEF := s.config().ReadNDEF; readNDEF == nil || !*readNDEF || !slices.Contains(codes, felicaSystemCodeNDEF) {
+		return
+	}
+	records, err := readFeliCaNDEF(ctx, device, fi.idm)
+	if err != nil {
+		s.logger.Warnw("failed to read NDEF", "uid", detectedTag.UID, "error", err)
+		return
+	}
+	info.ndefRecords = records
+	info.ndefRecordCount = len(records)
+	info.ndefText = firstNDEFText(records)
+}
+
+// felicaSystemCodeStrings formats system codes as 4-digit hex strings.
+func felicaSystemCodeStrings(codes []uint16) []interface{} {
+	out := make([]interface{}, 0, len(codes))
+	for _, c := range codes {
+		out = append(out, fmt.Sprintf("%04x", c))
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package pn532
+
+import (
+	"context"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+)
+
+var testIDm = []byte{0x01, 0x2E, 0x4C, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+// felicaResponse wraps a FeliCa response frame in an InDataExchange reply.
+func felicaResponse(frame ...byte) []byte {
+	return append([]byte{0x41, 0x00, byte(len(frame) + 1)}, frame...)
+}
+
+// felicaReadResponse is a successful Read Without Encryption of blocks.
+func felicaReadResponse(blocks []byte) []byte {
+	frame := append([]byte{0x07}, testIDm...)
+	frame = append(frame, 0x00, 0x00, byte(len(blocks)/felicaBlockSize))
+	return felicaResponse(append(frame, blocks...)...)
+}
+
+func felicaDetectedTag() *pn532lib.DetectedTag {
+	pol := append([]byte{0x01}, testIDm...)
+	pol = append(pol, 0x10, 0x0B, 0x4B, 0x42, 0x84, 0x85, 0xD0, 0xFF)
+	return &pn532lib.DetectedTag{
+		UID:        "012e4c1122334455",
+		UIDBytes:   testIDm,
+		Type:       pn532lib.TagTypeFeliCa,
+		TargetData: pol,
+	}
+}
+
+// liteSNDEF returns the AIB and padded data blocks of a Type 3 Tag holding
+// message.
+func liteSNDEF(message []byte) (aib, blocks []byte) {
+	aib = []byte{0x10, 0x04, 0x01, 0x00, 0x0D, 0, 0, 0, 0, 0x00, 0x01,
+		byte(len(message) >> 16), byte(len(message) >> 8), byte(len(message))}
+	var sum uint16
+	for _, b := range aib {
+		sum += uint16(b)
+	}
+	aib = append(aib, byte(sum>>8), byte(sum))
+
+	blocks = append(blocks, message...)
+	for len(blocks)%felicaBlockSize != 0 {
+		blocks = append(blocks, 0x00)
+	}
+	return aib, blocks
+}
+
+func TestOnCardDetectedFeliCa(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "uart", DevicePath: "/dev/ttyUSB0", PollMode: pollModeAutopoll})
+	mock.SelectTarget()
+
+	tlv, err := encodeNDEFTLV([]*ndef.Record{ndef.NewTextRecord("badge 0042", "ja")})
+	if err != nil {
+		t.Fatalf("encodeNDEFTLV: %v", err)
+	}
+	aib, blocks := liteSNDEF(tlv[2 : len(tlv)-1])
+
+	systemCodes := append([]byte{0x0D}, testIDm...)
+	systemCodes = append(systemCodes, 0x02, 0x88, 0xB4, 0x12, 0xFC)
+	mock.QueueResponse(0x40, felicaResponse(systemCodes...))
+	mock.QueueResponse(0x40, felicaReadResponse(aib))
+	mock.QueueResponse(0x40, felicaReadResponse(blocks))
+
+	if err := s.onCardDetected(context.Background(), felicaDetectedTag()); err != nil {
+		t.Fatalf("onCardDetected returned error: %v", err)
+	}
+
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	if readings["tag_type"] != "FELICA" {
+		t.Errorf("tag_type = %v, want FELICA", readings["tag_type"])
+	}
+	if readings["felica_idm"] != "012e4c1122334455" {
+		t.Errorf("felica_idm = %v", readings["felica_idm"])
+	}
+	if readings["felica_pmm"] != "100b4b428485d0ff" {
+		t.Errorf("felica_pmm = %v", readings["felica_pmm"])
+	}
+	codes, _ := readings["felica_system_codes"].([]interface{})
+	if len(codes) != 2 || codes[0] != "88b4" || codes[1] != "12fc" {
+		t.Errorf("felica_system_codes = %v, want [88b4 12fc]", readings["felica_system_codes"])
+	}
+	if readings["ndef_text"] != "badge 0042" {
+		t.Errorf("ndef_text = %v, want \"badge 0042\"", readings["ndef_text"])
+	}
+	if readings["ndef_record_count"] != 1 {
+		t.Errorf("ndef_record_count = %v, want 1", readings["ndef_record_count"])
+	}
+}
+
+func TestOnCardDetectedFeliCaWithoutNDEF(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "uart", DevicePath: "/dev/ttyUSB0", PollMode: pollModeAutopoll})
+	mock.SelectTarget()
+
+	// A transit card: one proprietary system, no NFC Forum Type 3 system.
+	systemCodes := append([]byte{0x0D}, testIDm...)
+	systemCodes = append(systemCodes, 0x01, 0x00, 0x03)
+	mock.QueueResponse(0x40, felicaResponse(systemCodes...))
+
+	if err := s.onCardDetected(context.Background(), felicaDetectedTag()); err != nil {
+		t.Fatalf("onCardDetected returned error: %v", err)
+	}
+	if got := mock.GetCallCount(0x40); got != 1 {
+		t.Errorf("InDataExchange calls = %d, want 1 (no NDEF read)", got)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.state.ndefRecordCount != 0 {
+		t.Errorf("ndefRecordCount = %d, want 0", s.state.ndefRecordCount)
+	}
+	if len(s.state.felicaSystemCodes) != 1 || s.state.felicaSystemCodes[0] != 0x0003 {
+		t.Errorf("felicaSystemCodes = %v, want [0003]", s.state.felicaSystemCodes)
+	}
+}
+
+func TestReadFeliCaNDEFChecksum(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "uart", DevicePath: "/dev/ttyUSB0", PollMode: pollModeAutopoll})
+	mock.SelectTarget()
+
+	aib, _ := liteSNDEF([]byte{0xD1, 0x01, 0x00, 0x54})
+	aib[15]++
+	mock.QueueResponse(0x40, felicaReadResponse(aib))
+
+	if _, err := readFeliCaNDEF(context.Background(), s.device, testIDm); err == nil {
+		t.Error("readFeliCaNDEF should reject an AIB with a bad checksum")
+	}
+}
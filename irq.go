@@ -0,0 +1,264 @@
+package pn532
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/polling"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	defaultIRQFallbackMs = 5000
+
+	// irqArmTimeout bounds the InListPassiveTarget that arms the PN532. The
+	// PN532 keeps searching after the host stops waiting (passive activation
+	// retries are infinite) and pulls IRQ low when a target answers.
+	irqArmTimeout = 100 * time.Millisecond
+	// irqMissWindow is how soon after a fallback wake a detection counts as
+	// a tag that arrived without an interrupt.
+	irqMissWindow = time.Second
+	// irqMaxMisses is how many consecutive missed interrupts disable IRQ
+	// gating for the rest of the session.
+	irqMaxMisses = 3
+)
+
+// irqWake is why an idle period ended.
+type irqWake int
+
+const (
+	wakeIRQ irqWake = iota
+	wakeTag
+	wakeRequest
+	wakeFallback
+	wakeClosed
+)
+
+// irqGate idles the polling session while the field is empty and resumes it
+// when the PN532 IRQ line fires, so the reader is not polled continuously.
+// Every idle period is bounded by fallback, and the gate gives up in favour
+// of plain polling if tags keep arriving without an interrupt.
+type irqGate struct {
+	board    board.Board
+	pin      board.DigitalInterrupt
+	fallback time.Duration
+	logger   logging.Logger
+
+	// wake is signalled when a DoCommand needs the device or releases it.
+	wake chan struct{}
+	// holds counts DoCommands that need polling to run; the gate does not
+	// idle while it is non-zero.
+	holds  atomic.Int32
+	active atomic.Bool
+}
+
+// newIRQGate resolves irq_pin on the configured board. It returns nil if no
+// IRQ pin is configured.
+func newIRQGate(deps resource.Dependencies, cfg *Config, logger logging.Logger) (*irqGate, error) {
+	if cfg.IRQPin == "" {
+		return nil, nil
+	}
+	b, err := board.FromDependencies(deps, cfg.Board)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board %q: %w", cfg.Board, err)
+	}
+	pin, err := b.DigitalInterruptByName(cfg.IRQPin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IRQ pin %q: %w", cfg.IRQPin, err)
+	}
+	fallbackMs := cfg.IRQFallbackMs
+	if fallbackMs <= 0 {
+		fallbackMs = defaultIRQFallbackMs
+	}
+	return &irqGate{
+		board:    b,
+		pin:      pin,
+		fallback: time.Duration(fallbackMs) * time.Millisecond,
+		logger:   logger,
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// hold keeps the session polling until the returned release is called. It
+// interrupts an idle period in progress. A nil gate is a no-op.
+func (g *irqGate) hold() func() {
+	if g == nil {
+		return func() {}
+	}
+	g.holds.Add(1)
+	g.signal()
+	return func() {
+		g.holds.Add(-1)
+		g.signal()
+	}
+}
+
+func (g *irqGate) signal() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// detectionMode reports "irq" while the gate is idling the session between
+// tags and "polling" otherwise.
+func (g *irqGate) detectionMode() string {
+	if g != nil && g.active.Load() {
+		return "irq"
+	}
+	return "polling"
+}
+
+// runIRQGate idles sess between tags until ctx is done or the interrupt
+// proves unreliable. It runs for the lifetime of one polling session.
+func (s *pn532Sensor) runIRQGate(ctx context.Context, sess *polling.Session) {
+	g := s.irq
+	ticks := make(chan board.Tick, 8)
+	if err := g.board.StreamTicks(ctx, []board.DigitalInterrupt{g.pin}, ticks, nil); err != nil {
+		s.logger.Warnw("failed to stream IRQ pin, falling back to polling", "pin", g.pin.Name(), "error", err)
+		return
+	}
+	g.active.Store(true)
+	defer g.active.Store(false)
+
+	misses := 0
+	for {
+		if !s.waitForEmptyField(ctx) {
+			return
+		}
+
+		cursor := s.lastEventSeq()
+		wake, err := g.idle(ctx, sess, ticks)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.Debugw("IRQ idle failed", "error", err)
+			continue
+		}
+
+		switch wake {
+		case wakeClosed:
+			return
+		case wakeIRQ:
+			misses = 0
+		case wakeFallback:
+			if s.detectedSince(ctx, cursor, irqMissWindow) {
+				misses++
+				if misses >= irqMaxMisses {
+					s.logger.Warnw("tags detected without an IRQ edge, falling back to polling", "pin", g.pin.Name())
+					return
+				}
+			}
+		case wakeTag, wakeRequest:
+		}
+	}
+}
+
+// idle pauses sess, arms the PN532, and blocks until the IRQ pin fires, a
+// DoCommand needs the device, the fallback interval elapses, or ctx is done.
+// The session resumes when idle returns.
+func (g *irqGate) idle(ctx context.Context, sess *polling.Session, ticks <-chan board.Tick) (irqWake, error) {
+	wake := wakeClosed
+	err := sess.PauseAndRun(ctx, func(dev *pn532.Device) error {
+		if g.holds.Load() > 0 {
+			wake = wakeRequest
+			return nil
+		}
+
+		armCtx, cancel := context.WithTimeout(ctx, irqArmTimeout)
+		tag, _ := dev.InListPassiveTarget(armCtx, 0x00)
+		cancel()
+		if tag != nil {
+			// Already in the field; the session will detect it on resume.
+			wake = wakeTag
+			return nil
+		}
+
+		// Discard edges from the arming exchange itself.
+		for drained := false; !drained; {
+			select {
+			case <-ticks:
+			default:
+				drained = true
+			}
+		}
+
+		timer := time.NewTimer(g.fallback)
+		defer timer.Stop()
+		select {
+		case <-ticks:
+			wake = wakeIRQ
+		case <-g.wake:
+			wake = wakeRequest
+		case <-timer.C:
+			wake = wakeFallback
+		case <-ctx.Done():
+			return nil
+		}
+
+		// The armed command's response is never read; drop any partial
+		// frame state before the session talks to the PN532 again.
+		if err := dev.ClearTransportState(); err != nil {
+			g.logger.Debugw("failed to clear transport state after IRQ wake", "error", err)
+		}
+		return nil
+	})
+	return wake, err
+}
+
+// waitForEmptyField blocks until no tag is present and no DoCommand holds the
+// gate. It returns false if ctx is done first.
+func (s *pn532Sensor) waitForEmptyField(ctx context.Context) bool {
+	for {
+		s.mu.Lock()
+		empty := !s.state.tagPresent
+		changed := s.events.wait()
+		s.mu.Unlock()
+
+		if empty && s.irq.holds.Load() == 0 {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-s.irq.wake:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (s *pn532Sensor) lastEventSeq() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.events.lastSeq
+}
+
+// detectedSince reports whether a tag is detected after cursor within window.
+func (s *pn532Sensor) detectedSince(ctx context.Context, cursor uint64, window time.Duration) bool {
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		_, found := s.events.firstAfter(cursor, eventDetected)
+		changed := s.events.wait()
+		s.mu.Unlock()
+		if found {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package pn532
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/go-pn532/polling"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+)
+
+func newTestIRQGate(t *testing.T, fallback time.Duration) *irqGate {
+	t.Helper()
+	return &irqGate{
+		fallback: fallback,
+		logger:   logging.NewTestLogger(t),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func TestIRQGateIdleWakes(t *testing.T) {
+	s, _ := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	sess := polling.NewSession(s.device, nil)
+
+	t.Run("interrupt", func(t *testing.T) {
+		g := newTestIRQGate(t, 5*time.Second)
+		ticks := make(chan board.Tick, 1)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			ticks <- board.Tick{High: false}
+		}()
+
+		wake, err := g.idle(context.Background(), sess, ticks)
+		if err != nil || wake != wakeIRQ {
+			t.Errorf("idle = %v, %v; want wakeIRQ", wake, err)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		g := newTestIRQGate(t, 20*time.Millisecond)
+		wake, err := g.idle(context.Background(), sess, make(chan board.Tick))
+		if err != nil || wake != wakeFallback {
+			t.Errorf("idle = %v, %v; want wakeFallback", wake, err)
+		}
+	})
+
+	t.Run("request", func(t *testing.T) {
+		g := newTestIRQGate(t, 5*time.Second)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			release := g.hold()
+			defer release()
+		}()
+
+		wake, err := g.idle(context.Background(), sess, make(chan board.Tick))
+		if err != nil || wake != wakeRequest {
+			t.Errorf("idle = %v, %v; want wakeRequest", wake, err)
+		}
+	})
+}
+
+func TestIRQGateNilIsPolling(t *testing.T) {
+	var g *irqGate
+	g.hold()()
+	if mode := g.detectionMode(); mode != "polling" {
+		t.Errorf("detectionMode = %q, want polling", mode)
+	}
+}
@@ -2,8 +2,161 @@ package pn532
 
 import (
 	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+)
+
+const (
+	reconnectInitialBackoff = time.Second
+	reconnectMaxBackoff     = 30 * time.Second
 )
 
+// superviseConnection waits for the polling session to stop while the sensor
+// is still open (normally after onDeviceDisconnected), tears the old session
+// and device down, and reconnects with exponential backoff and jitter. A
+// session stopped by Reconfigure is either restarted on the same device or,
+// if transport or device_path changed, replaced by a fresh connection.
+func (s *pn532Sensor) superviseConnection() {
+	defer s.supervisorWg.Done()
+
+	for {
+		var exitErr error
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case exitErr = <-s.sessionExited:
+		}
+
+		if errors.Is(exitErr, errSessionRestart) {
+			if device := s.stopSession(); device != nil {
+				s.startSession(device)
+				continue
+			}
+		}
+		reconfigured := errors.Is(exitErr, errReconfigured)
+
+		s.teardownSession()
+
+		s.mu.Lock()
+		// onDeviceDisconnected has already recorded the error if it ran;
+		// otherwise the session stopped for another reason.
+		if s.state.deviceHealthy && !reconfigured {
+			if exitErr != nil {
+				s.state.lastDisconnectError = exitErr.Error()
+			}
+			s.events.add(tagEvent{kind: eventDeviceDisconnected, err: errorString(exitErr)})
+		}
+		s.state.deviceHealthy = false
+		s.state.reconnectAttempts = 0
+		s.state.clearTag()
+		s.mu.Unlock()
+
+		device, ok := s.reconnect()
+		if !ok {
+			return
+		}
+		s.mu.Lock()
+		s.logger.Infow("PN532 reconnected", "transport", s.state.transport, "device_path", s.state.devicePath)
+		if !s.closed && !reconfigured {
+			s.events.add(tagEvent{kind: eventDeviceReconnected})
+		}
+		s.mu.Unlock()
+		s.startSession(device)
+	}
+}
+
+// stopSession waits for the session goroutine to exit and closes the session,
+// keeping the device open for the next one. It returns the device, or nil if
+// Close has taken ownership.
+func (s *pn532Sensor) stopSession() *pn532.Device {
+	s.sessionWg.Wait()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	sess, device := s.session, s.device
+	s.session = nil
+	s.mu.Unlock()
+
+	if sess != nil {
+		if err := sess.Close(); err != nil {
+			s.logger.Errorw("error closing polling session", "error", err)
+		}
+	}
+	return device
+}
+
+// teardownSession waits for the session goroutine to exit, then closes the
+// session and device. It leaves both alone if Close has taken ownership.
+func (s *pn532Sensor) teardownSession() {
+	s.sessionWg.Wait()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	sess, device, path := s.session, s.device, s.state.devicePath
+	s.session, s.device = nil, nil
+	s.mu.Unlock()
+
+	if sess != nil {
+		if err := sess.Close(); err != nil {
+			s.logger.Errorw("error closing polling session", "error", err)
+		}
+	}
+	if device != nil {
+		if err := device.Close(); err != nil {
+			s.logger.Debugw("error closing disconnected PN532 device", "error", err)
+		}
+		openDevices.release(path)
+	}
+}
+
+// reconnect calls connect until it succeeds or the sensor is closed. The first
+// attempt is immediate; later attempts back off exponentially with ±20% jitter.
+func (s *pn532Sensor) reconnect() (*pn532.Device, bool) {
+	backoff := reconnectInitialBackoff
+	for {
+		s.mu.Lock()
+		s.state.reconnectAttempts++
+		attempt := s.state.reconnectAttempts
+		gen := s.cfgGen
+		s.mu.Unlock()
+
+		device, err := s.connect(s.cancelCtx)
+		if err == nil {
+			s.mu.Lock()
+			s.deviceGen = gen
+			s.mu.Unlock()
+			return device, true
+		}
+		if s.cancelCtx.Err() != nil {
+			return nil, false
+		}
+
+		wait := jitter(backoff)
+		s.logger.Warnw("PN532 reconnect failed", "attempt", attempt, "retry_in", wait, "error", err)
+
+		select {
+		case <-s.cancelCtx.Done():
+			return nil, false
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, reconnectMaxBackoff)
+	}
+}
+
+// jitter returns d scaled by a random factor in [0.8, 1.2).
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+}
+
 func (s *pn532Sensor) Close(_ context.Context) error {
 	s.mu.Lock()
 	if s.closed {
@@ -11,9 +164,11 @@ func (s *pn532Sensor) Close(_ context.Context) error {
 		return nil
 	}
 	s.closed = true
+	device, path := s.device, s.state.devicePath
 	s.mu.Unlock()
 
-	// Cancel context first — signals the polling loop in Start() to exit.
+	// Cancel context first — signals the polling loop in Start() and the
+	// reconnect supervisor to exit.
 	s.cancelFunc()
 
 	// Close the device (I2C transport) before waiting for the goroutine.
@@ -21,17 +176,27 @@ func (s *pn532Sensor) Close(_ context.Context) error {
 	// operation that context cancellation cannot interrupt. Closing the
 	// transport's file descriptor causes the in-flight I2C syscall to
 	// return immediately with an error, unblocking the polling loop.
-	if s.device != nil {
-		if err := s.device.Close(); err != nil {
+	if device != nil {
+		if err := device.Close(); err != nil {
 			s.logger.Errorw("error closing PN532 device", "error", err)
 		}
+		openDevices.release(path)
 	}
 
-	// Now the goroutine can exit promptly.
+	// The supervisor may be mid-reconnect; once it exits no new session can
+	// be started, so the session wait below is final.
+	s.supervisorWg.Wait()
 	s.sessionWg.Wait()
+	s.mu.RLock()
+	relay := s.relay
+	s.mu.RUnlock()
+	relay.wait()
 
-	if s.session != nil {
-		if err := s.session.Close(); err != nil {
+	s.mu.RLock()
+	sess := s.session
+	s.mu.RUnlock()
+	if sess != nil {
+		if err := sess.Close(); err != nil {
 			s.logger.Errorw("error closing polling session", "error", err)
 		}
 	}
@@ -0,0 +1,201 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+)
+
+// ntagCmdGetVersion is sent with InCommunicateThru: through InDataExchange
+// the PN532 would treat 0x60 as a MIFARE Classic authentication.
+const ntagCmdGetVersion = 0x60
+
+const (
+	// ntagStaticLockPage holds the static lock bytes in bytes 2 and 3; they
+	// lock pages 3 (CC) to 15 and freeze their own block-locking bits.
+	ntagStaticLockPage = 2
+	ntagCCPage         = 3
+	// ntagCCReadOnly in CC byte 3 marks the NDEF message read-only for
+	// readers that honour the capability container.
+	ntagCCReadOnly = 0x0F
+	ntagCCMagic    = 0xE1
+)
+
+// ntagLockLayout describes where the lock bits of one tag model live.
+type ntagLockLayout struct {
+	variant    string
+	totalPages int
+	// dynLockPage holds the dynamic lock bytes for pages 16 and above, or 0
+	// if the tag has none. dynLock sets every lock and block-locking bit of
+	// the model; RFUI bits must be written as 0.
+	dynLockPage int
+	dynLock     []byte
+}
+
+// ntagLockLayouts maps the GET_VERSION product type and storage size bytes
+// to a lock layout. Lock granularity is 2 pages on NTAG213 and Ultralight EV1
+// and 16 pages on NTAG215/216, with one block-locking bit per two lock bits.
+var ntagLockLayouts = map[[2]byte]ntagLockLayout{
+	{0x03, 0x0B}: {variant: "MF0UL11", totalPages: 20},
+	{0x03, 0x0E}: {variant: "MF0UL21", totalPages: 41, dynLockPage: 0x24, dynLock: []byte{0xFF, 0x03, 0x1F}},
+	{0x04, 0x0F}: {variant: "NTAG213", totalPages: 45, dynLockPage: 0x28, dynLock: []byte{0xFF, 0x0F, 0x3F}},
+	{0x04, 0x11}: {variant: "NTAG215", totalPages: 135, dynLockPage: 0x82, dynLock: []byte{0xFF, 0x00, 0x0F}},
+	{0x04, 0x13}: {variant: "NTAG216", totalPages: 231, dynLockPage: 0xE2, dynLock: []byte{0xFF, 0x3F, 0x7F}},
+}
+
+// ntagLockLayoutFor identifies the tag with GET_VERSION. Tags without
+// GET_VERSION (original Ultralight and Ultralight C) are not supported: their
+// size cannot be told apart reliably.
+func ntagLockLayoutFor(ctx context.Context, device *pn532.Device) (ntagLockLayout, error) {
+	res, err := device.SendRawCommand(ctx, []byte{ntagCmdGetVersion})
+	if err != nil {
+		_ = reselectTag(ctx, device)
+		return ntagLockLayout{}, fmt.Errorf("GET_VERSION failed: %w", err)
+	}
+	if len(res) < 8 || res[1] != 0x04 {
+		return ntagLockLayout{}, fmt.Errorf("unexpected GET_VERSION response %X", res)
+	}
+	layout, ok := ntagLockLayouts[[2]byte{res[2], res[6]}]
+	if !ok {
+		return ntagLockLayout{}, fmt.Errorf("unsupported tag (product type 0x%02X, storage size 0x%02X)", res[2], res[6])
+	}
+	return layout, nil
+}
+
+// lockChange is one page write of a lock operation.
+type lockChange struct {
+	name    string
+	page    int
+	current []byte
+	data    []byte
+}
+
+func (c lockChange) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    c.name,
+		"page":    c.page,
+		"current": hex.EncodeToString(c.current),
+		"new":     hex.EncodeToString(c.data),
+	}
+}
+
+// planLock returns the page writes that make the tag permanently read-only,
+// in the order they must be written: the CC and dynamic lock bytes first,
+// since the static lock bits also lock the CC page. Bits that are already set
+// produce no write.
+func planLock(ctx context.Context, device *pn532.Device, layout ntagLockLayout) ([]lockChange, error) {
+	header, err := ntagReadPages(ctx, device, ntagStaticLockPage)
+	if err != nil {
+		return nil, err
+	}
+	static := header[0:4]
+	cc := header[4:8]
+	if cc[0] != ntagCCMagic {
+		return nil, fmt.Errorf("tag is not NDEF formatted (CC %X)", cc)
+	}
+
+	var changes []lockChange
+	add := func(name string, page int, current, data []byte) {
+		if !bytes.Equal(current, data) {
+			changes = append(changes, lockChange{name: name, page: page, current: current, data: data})
+		}
+	}
+
+	newCC := bytes.Clone(cc)
+	newCC[3] = ntagCCReadOnly
+	add("capability_container", ntagCCPage, cc, newCC)
+
+	if layout.dynLockPage != 0 {
+		data, err := ntagReadPages(ctx, device, layout.dynLockPage)
+		if err != nil {
+			return nil, err
+		}
+		dyn := data[0:4]
+		newDyn := bytes.Clone(dyn)
+		for i, b := range layout.dynLock {
+			newDyn[i] |= b
+		}
+		// Byte 3 is RFUI and reads as BDh; write it as 0.
+		newDyn[3] = 0x00
+		current := append(bytes.Clone(dyn[:3]), 0x00)
+		add("dynamic_lock", layout.dynLockPage, current, newDyn)
+	}
+
+	newStatic := bytes.Clone(static)
+	newStatic[2], newStatic[3] = 0xFF, 0xFF
+	add("static_lock", ntagStaticLockPage, static, newStatic)
+	return changes, nil
+}
+
+// handleLockTag permanently write-protects the NTAG or Ultralight EV1 in the
+// field: the CC is marked read-only and every static and dynamic lock bit is
+// set. The operation is refused unless uid matches the tag in the field, and
+// needs confirm unless dry_run only reports the planned writes.
+func (s *pn532Sensor) handleLockTag(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	uid, _ := cmd["uid"].(string)
+	if uid == "" {
+		return nil, fmt.Errorf("lock_tag: missing \"uid\" field")
+	}
+	dryRun, _ := cmd["dry_run"].(bool)
+	if confirm, _ := cmd["confirm"].(bool); !confirm && !dryRun {
+		return nil, fmt.Errorf("lock_tag: locking is irreversible; pass \"confirm\": true (or \"dry_run\": true)")
+	}
+
+	var result map[string]interface{}
+	err := s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		if !strings.EqualFold(tag.UID, uid) {
+			return fmt.Errorf("tag in field is %s, not %s", tag.UID, uid)
+		}
+		if tag.Type != pn532.TagTypeNTAG {
+			return fmt.Errorf("tag %s is %s, not NTAG or Ultralight", tag.UID, tag.Type)
+		}
+		layout, err := ntagLockLayoutFor(ctx, device)
+		if err != nil {
+			return err
+		}
+		s.authenticateConfigured(ctx, device, tag, true, ntagUserEnd(layout))
+
+		changes, err := planLock(ctx, device, layout)
+		if err != nil {
+			return err
+		}
+		planned := make([]interface{}, 0, len(changes))
+		for _, c := range changes {
+			planned = append(planned, c.toMap())
+		}
+		result = map[string]interface{}{
+			"uid":     tag.UID,
+			"variant": layout.variant,
+			"dry_run": dryRun,
+			"changes": planned,
+		}
+		if dryRun {
+			result["locked"] = false
+			return nil
+		}
+
+		for _, c := range changes {
+			if err := ntagWritePage(ctx, device, c.page, c.data); err != nil {
+				return err
+			}
+		}
+		remaining, err := planLock(ctx, device, layout)
+		if err != nil {
+			return fmt.Errorf("lock verification failed: %w", err)
+		}
+		if len(remaining) > 0 {
+			return fmt.Errorf("lock verification failed: %s at page %d reads %X",
+				remaining[0].name, remaining[0].page, remaining[0].current)
+		}
+		result["locked"] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lock_tag: %w", err)
+	}
+	return result, nil
+}
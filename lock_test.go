@@ -0,0 +1,122 @@
+package pn532
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+)
+
+// ntag215Version is a GET_VERSION reply through InCommunicateThru.
+var ntag215Version = []byte{0x43, 0x00, 0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x11, 0x03}
+
+// ntagPagesResponse is a READ reply for four pages.
+func ntagPagesResponse(pages ...[]byte) []byte {
+	res := []byte{0x41, 0x00}
+	for _, p := range pages {
+		res = append(res, p...)
+	}
+	for len(res) < 2+4*ntagPageSize {
+		res = append(res, 0x00)
+	}
+	return res
+}
+
+// queueLockState queues the two reads of planLock: pages 2-5 with the static
+// lock bytes and CC, and the NTAG215 dynamic lock page.
+func queueLockState(mock *pn532lib.MockTransport, static, cc, dyn []byte) {
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x9A, 0x48, static[0], static[1]}, cc))
+	mock.QueueResponse(0x40, ntagPagesResponse(dyn))
+}
+
+func TestLockTagDryRun(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag215Version)
+	queueLockState(mock, []byte{0x00, 0x00}, []byte{0xE1, 0x10, 0x3E, 0x00}, []byte{0x00, 0x00, 0x00, 0xBD})
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":  "lock_tag",
+		"uid":     "04123456789ABC",
+		"dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("lock_tag dry_run: %v", err)
+	}
+	if result["variant"] != "NTAG215" || result["locked"] != false {
+		t.Errorf("variant, locked = %v, %v, want NTAG215, false", result["variant"], result["locked"])
+	}
+	changes := result["changes"].([]interface{})
+	want := []struct {
+		name    string
+		page    int
+		current string
+		data    string
+	}{
+		{"capability_container", 3, "e1103e00", "e1103e0f"},
+		{"dynamic_lock", 0x82, "00000000", "ff000f00"},
+		{"static_lock", 2, "9a480000", "9a48ffff"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %d", changes, len(want))
+	}
+	for i, w := range want {
+		c := changes[i].(map[string]interface{})
+		if c["name"] != w.name || c["page"] != w.page || c["current"] != w.current || c["new"] != w.data {
+			t.Errorf("changes[%d] = %v, want %+v", i, c, w)
+		}
+	}
+	if got := mock.GetCallCount(0x40); got != 2 {
+		t.Errorf("InDataExchange calls = %d, want 2 (reads only)", got)
+	}
+}
+
+func TestLockTagWritesAndVerifies(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag215Version)
+	queueLockState(mock, []byte{0x00, 0x00}, []byte{0xE1, 0x10, 0x3E, 0x00}, []byte{0x01, 0x00, 0x00, 0xBD})
+	mock.QueueResponses(0x40, ntagAck, ntagAck, ntagAck)
+	queueLockState(mock, []byte{0xFF, 0xFF}, []byte{0xE1, 0x10, 0x3E, 0x0F}, []byte{0xFF, 0x00, 0x0F, 0xBD})
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":  "lock_tag",
+		"uid":     "04123456789abc",
+		"confirm": true,
+	})
+	if err != nil {
+		t.Fatalf("lock_tag: %v", err)
+	}
+	if result["locked"] != true {
+		t.Errorf("locked = %v, want true", result["locked"])
+	}
+	if got := mock.GetCallCount(0x40); got != 7 {
+		t.Errorf("InDataExchange calls = %d, want 7 (2 reads, 3 writes, 2 verify reads)", got)
+	}
+}
+
+func TestLockTagRefusals(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "lock_tag",
+		"uid":    "04123456789abc",
+	})
+	if err == nil || !strings.Contains(err.Error(), "confirm") {
+		t.Errorf("expected confirm error, got %v", err)
+	}
+	if got := mock.GetCallCount(0x4A); got != 0 {
+		t.Errorf("InListPassiveTarget calls = %d, want 0", got)
+	}
+
+	_, err = s.DoCommand(context.Background(), map[string]interface{}{
+		"action":  "lock_tag",
+		"uid":     "04aabbccddeeff",
+		"confirm": true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "not 04aabbccddeeff") {
+		t.Errorf("expected UID mismatch error, got %v", err)
+	}
+	if got := mock.GetCallCount(0x42) + mock.GetCallCount(0x40); got != 0 {
+		t.Errorf("tag commands = %d, want 0", got)
+	}
+}
@@ -0,0 +1,578 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+)
+
+// MIFARE Classic commands, sent through InDataExchange.
+const (
+	mifareCmdAuthA = 0x60
+	mifareCmdAuthB = 0x61
+	mifareCmdRead  = 0x30
+	mifareCmdWrite = 0xA0
+)
+
+const (
+	mifareBlockSize = 16
+	mifareKeySize   = 6
+
+	// Sectors 0–31 have 4 blocks; sectors 32–39 of a MIFARE Classic 4K have
+	// 16. The last block of every sector is its trailer (keys and access
+	// bits).
+	mifareSmallSectors    = 32
+	mifareSmallSectorSize = 4
+	mifareLargeSectorSize = 16
+	mifareFirstLargeBlock = mifareSmallSectors * mifareSmallSectorSize
+	mifareManufacturerBlk = 0
+
+	// mifareAuthErrorCode is the PN532 status for a rejected key.
+	mifareAuthErrorCode = 0x14
+)
+
+// mifareKeyStoreFileName is the file in $VIAM_MODULE_DATA holding keys added
+// with add_key.
+const mifareKeyStoreFileName = "mifare_keys.json"
+
+// errNoMIFAREKey is returned when no key in the store authenticates a
+// sector.
+var errNoMIFAREKey = errors.New("no key in the key store unlocks sector")
+
+// Key sources reported by list_keys and alongside every read or write.
+const (
+	keySourceConfig  = "config"
+	keySourceAdded   = "added"
+	keySourceDefault = "default"
+)
+
+// defaultMIFAREKeys are well-known keys tried after the configured and added
+// ones: the factory default, the MAD key, the NFC Forum NDEF key, and zeros.
+var defaultMIFAREKeys = []string{"ffffffffffff", "a0a1a2a3a4a5", "d3f7d3f7d3f7", "000000000000"}
+
+// mifareKey is a key store entry. Keys are never returned in full.
+type mifareKey struct {
+	key    []byte
+	source string
+}
+
+// mifareKeyStore holds the keys tried when authenticating to a MIFARE
+// Classic sector, in order: mifare_keys from the config, keys added with
+// add_key, then defaultMIFAREKeys.
+type mifareKeyStore struct {
+	mu     sync.RWMutex
+	config [][]byte
+	added  *addedMIFAREKeys // nil in a zero mifareKeyStore until add
+}
+
+// addedMIFAREKeys are the keys added with add_key, persisted to path. Key
+// stores of sensors using the same file share them.
+type addedMIFAREKeys struct {
+	mu   sync.RWMutex
+	path string
+	keys [][]byte
+}
+
+// addedMIFAREKeyFiles shares one addedMIFAREKeys per file among the sensors
+// in this process.
+var addedMIFAREKeyFiles storeCache[addedMIFAREKeys]
+
+// parseMIFAREKey decodes a 6-byte key given as 12 hex digits.
+func parseMIFAREKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != mifareKeySize {
+		return nil, fmt.Errorf("invalid MIFARE key %q, must be %d hex digits", maskKeyString(s), 2*mifareKeySize)
+	}
+	return key, nil
+}
+
+// maskKeyString hides all but the last two hex digits of a key.
+func maskKeyString(s string) string {
+	if len(s) <= 2 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-2) + s[len(s)-2:]
+}
+
+// mifareKeyStorePath returns the key store file in the module data directory,
+// or "" if there is none.
+func mifareKeyStorePath() string {
+	if dir := os.Getenv("VIAM_MODULE_DATA"); dir != "" {
+		return filepath.Join(dir, mifareKeyStoreFileName)
+	}
+	return ""
+}
+
+// loadMIFAREKeyStore builds a key store from the configured keys and the
+// added keys persisted at path.
+func loadMIFAREKeyStore(cfg *Config, path string) (*mifareKeyStore, error) {
+	return newMIFAREKeyStore(cfg, path, loadAddedMIFAREKeys)
+}
+
+// openMIFAREKeyStore is loadMIFAREKeyStore with the added keys shared with
+// every other sensor in this process using the same file.
+func openMIFAREKeyStore(cfg *Config, path string) (*mifareKeyStore, error) {
+	return newMIFAREKeyStore(cfg, path, func(path string) (*addedMIFAREKeys, error) {
+		return addedMIFAREKeyFiles.open(path, loadAddedMIFAREKeys)
+	})
+}
+
+func newMIFAREKeyStore(cfg *Config, path string, load func(string) (*addedMIFAREKeys, error)) (*mifareKeyStore, error) {
+	config, err := parseMIFAREKeys(cfg.MIFAREKeys)
+	if err != nil {
+		return nil, err
+	}
+	added, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mifareKeyStore{config: config, added: added}, nil
+}
+
+// loadAddedMIFAREKeys reads the added keys at path. A file that cannot be
+// parsed is an error so it is never overwritten.
+func loadAddedMIFAREKeys(path string) (*addedMIFAREKeys, error) {
+	a := &addedMIFAREKeys{path: path}
+	if path == "" {
+		return a, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MIFARE key store: %w", err)
+	}
+	var added []string
+	if err := json.Unmarshal(data, &added); err != nil {
+		return nil, fmt.Errorf("failed to parse MIFARE key store %s: %w", path, err)
+	}
+	for _, s := range added {
+		key, err := parseMIFAREKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("MIFARE key store %s: %w", path, err)
+		}
+		a.keys = append(a.keys, key)
+	}
+	return a, nil
+}
+
+// parseMIFAREKeys decodes the mifare_keys config entries.
+func parseMIFAREKeys(keys []string) ([][]byte, error) {
+	var out [][]byte
+	for _, s := range keys {
+		key, err := parseMIFAREKey(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+// setConfigured replaces the keys from mifare_keys, keeping added keys.
+func (ks *mifareKeyStore) setConfigured(keys [][]byte) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.config = keys
+}
+
+// keys returns every key in the order they are tried. Indexes into the
+// result are the key_index reported to callers.
+func (ks *mifareKeyStore) keys() []mifareKey {
+	ks.mu.RLock()
+	config, shared := ks.config, ks.added
+	ks.mu.RUnlock()
+	var added [][]byte
+	if shared != nil {
+		shared.mu.RLock()
+		added = shared.keys
+		shared.mu.RUnlock()
+	}
+
+	out := make([]mifareKey, 0, len(config)+len(added)+len(defaultMIFAREKeys))
+	for _, k := range config {
+		out = append(out, mifareKey{key: k, source: keySourceConfig})
+	}
+	for _, k := range added {
+		out = append(out, mifareKey{key: k, source: keySourceAdded})
+	}
+	for _, s := range defaultMIFAREKeys {
+		k, _ := hex.DecodeString(s)
+		out = append(out, mifareKey{key: k, source: keySourceDefault})
+	}
+	return out
+}
+
+// add appends key to the added keys and persists them. It reports false if
+// the key is already in the store.
+func (ks *mifareKeyStore) add(key []byte) (bool, error) {
+	for _, k := range ks.keys() {
+		if bytes.Equal(k.key, key) {
+			return false, nil
+		}
+	}
+	ks.mu.Lock()
+	if ks.added == nil {
+		ks.added = &addedMIFAREKeys{}
+	}
+	added := ks.added
+	ks.mu.Unlock()
+	return added.add(key)
+}
+
+// add appends key unless it is already present and persists the keys.
+func (a *addedMIFAREKeys) add(key []byte) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, k := range a.keys {
+		if bytes.Equal(k, key) {
+			return false, nil
+		}
+	}
+	// A new slice, so keys() results handed out earlier stay unchanged.
+	keys := append(slices.Clone(a.keys), key)
+	if err := a.save(keys); err != nil {
+		return false, err
+	}
+	a.keys = keys
+	return true, nil
+}
+
+// save writes keys to path, readable only by the module user.
+func (a *addedMIFAREKeys) save(keys [][]byte) error {
+	if a.path == "" {
+		return nil
+	}
+
+	added := make([]string, 0, len(keys))
+	for _, k := range keys {
+		added = append(added, hex.EncodeToString(k))
+	}
+	data, err := json.MarshalIndent(added, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode MIFARE key store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create MIFARE key store directory: %w", err)
+	}
+	if err := writeFileAtomic(a.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write MIFARE key store: %w", err)
+	}
+	return nil
+}
+
+// mifareSectorCount returns the number of sectors of a MIFARE Classic tag
+// from its SAK: Mini (0x09), 4K (0x18), otherwise 1K.
+func mifareSectorCount(sak byte) int {
+	switch sak {
+	case 0x09:
+		return 5
+	case 0x18:
+		return 40
+	default:
+		return 16
+	}
+}
+
+// mifareSectorBlocks returns the first block and block count of sector.
+func mifareSectorBlocks(sector int) (first, count int) {
+	if sector < mifareSmallSectors {
+		return sector * mifareSmallSectorSize, mifareSmallSectorSize
+	}
+	return mifareFirstLargeBlock + (sector-mifareSmallSectors)*mifareLargeSectorSize, mifareLargeSectorSize
+}
+
+// mifareBlockSector returns the sector holding block.
+func mifareBlockSector(block int) int {
+	if block < mifareFirstLargeBlock {
+		return block / mifareSmallSectorSize
+	}
+	return mifareSmallSectors + (block-mifareFirstLargeBlock)/mifareLargeSectorSize
+}
+
+// isMIFARETrailer reports whether block is a sector trailer.
+func isMIFARETrailer(block int) bool {
+	first, count := mifareSectorBlocks(mifareBlockSector(block))
+	return block == first+count-1
+}
+
+// mifareUnlock records which key authenticated a sector.
+type mifareUnlock struct {
+	index   int
+	keyType string
+	source  string
+}
+
+func (u mifareUnlock) addTo(result map[string]interface{}) {
+	result["key_index"] = u.index
+	result["key_type"] = u.keyType
+	result["key_source"] = u.source
+}
+
+// mifareSession is one selected MIFARE Classic tag. The caller must have
+// exclusive device access for its lifetime.
+type mifareSession struct {
+	device *pn532.Device
+	tag    *pn532.DetectedTag
+}
+
+// newMIFARESession checks that tag is MIFARE Classic.
+func newMIFARESession(device *pn532.Device, tag *pn532.DetectedTag) (*mifareSession, error) {
+	if tag.Type != pn532.TagTypeMIFARE {
+		return nil, fmt.Errorf("tag %s is %s, not MIFARE Classic", tag.UID, tag.Type)
+	}
+	return &mifareSession{device: device, tag: tag}, nil
+}
+
+// authenticate authenticates sector with one key. The authentication UID is
+// the last four bytes of the tag's UID.
+func (m *mifareSession) authenticate(ctx context.Context, sector int, keyCmd byte, key []byte) error {
+	first, _ := mifareSectorBlocks(sector)
+	uid := m.tag.UIDBytes
+	if len(uid) < 4 {
+		return fmt.Errorf("UID %X too short for MIFARE authentication", uid)
+	}
+	cmd := append([]byte{keyCmd, byte(first)}, key...)
+	cmd = append(cmd, uid[len(uid)-4:]...)
+	_, err := m.device.SendDataExchange(ctx, cmd)
+	return err
+}
+
+// withSectorKey tries every key in ks against sector, key A before key B,
+// and runs op once authenticated. If op fails, for example because the
+// access bits deny it with that key type, the remaining keys are tried. It
+// returns the key that op succeeded with.
+func (m *mifareSession) withSectorKey(ctx context.Context, ks *mifareKeyStore, sector int, op func() error) (mifareUnlock, error) {
+	var opErr error
+	for i, k := range ks.keys() {
+		for _, keyType := range []struct {
+			cmd  byte
+			name string
+		}{{mifareCmdAuthA, "A"}, {mifareCmdAuthB, "B"}} {
+			if err := ctx.Err(); err != nil {
+				return mifareUnlock{}, err
+			}
+
+			err := m.authenticate(ctx, sector, keyType.cmd, k.key)
+			if err == nil {
+				if err = op(); err == nil {
+					return mifareUnlock{index: i, keyType: keyType.name, source: k.source}, nil
+				}
+				opErr = err
+			} else if !isMIFAREAuthError(err) {
+				return mifareUnlock{}, fmt.Errorf("sector %d authentication failed: %w", sector, err)
+			}
+			if err := reselectTag(ctx, m.device); err != nil {
+				return mifareUnlock{}, fmt.Errorf("failed to reselect tag: %w", err)
+			}
+		}
+	}
+	if opErr != nil {
+		return mifareUnlock{}, opErr
+	}
+	return mifareUnlock{}, fmt.Errorf("%w %d", errNoMIFAREKey, sector)
+}
+
+// isMIFAREAuthError reports whether err is the tag rejecting a key, as
+// opposed to a transport failure.
+func isMIFAREAuthError(err error) bool {
+	var pnErr *pn532.PN532Error
+	return errors.As(err, &pnErr) && pnErr.ErrorCode == mifareAuthErrorCode
+}
+
+func (m *mifareSession) readBlock(ctx context.Context, block int) ([]byte, error) {
+	data, err := m.device.SendDataExchange(ctx, []byte{mifareCmdRead, byte(block)})
+	if err != nil {
+		return nil, fmt.Errorf("read of block %d failed: %w", block, err)
+	}
+	if len(data) < mifareBlockSize {
+		return nil, fmt.Errorf("read of block %d returned %d bytes, want %d", block, len(data), mifareBlockSize)
+	}
+	return data[:mifareBlockSize], nil
+}
+
+func (m *mifareSession) writeBlock(ctx context.Context, block int, data []byte) error {
+	cmd := append([]byte{mifareCmdWrite, byte(block)}, data...)
+	if _, err := m.device.SendDataExchange(ctx, cmd); err != nil {
+		return fmt.Errorf("write of block %d failed: %w", block, err)
+	}
+	return nil
+}
+
+// checkBlock validates a block number against the tag's size.
+func (m *mifareSession) checkBlock(block int) error {
+	first, count := mifareSectorBlocks(mifareSectorCount(m.tag.SAK) - 1)
+	if block < 0 || block >= first+count {
+		return fmt.Errorf("block %d out of range for this tag (0-%d)", block, first+count-1)
+	}
+	return nil
+}
+
+// runMIFARE gives fn the MIFARE Classic tag in the field with polling
+// paused.
+func (s *pn532Sensor) runMIFARE(ctx context.Context, fn func(m *mifareSession) error) error {
+	return s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		m, err := newMIFARESession(device, tag)
+		if err != nil {
+			return err
+		}
+		return fn(m)
+	})
+}
+
+func (s *pn532Sensor) handleAddKey(cmd map[string]interface{}) (map[string]interface{}, error) {
+	raw, ok := cmd["key"].(string)
+	if !ok || raw == "" {
+		return nil, fmt.Errorf("add_key: missing or invalid \"key\" field")
+	}
+	key, err := parseMIFAREKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("add_key: %w", err)
+	}
+	added, err := s.keys.add(key)
+	if err != nil {
+		return nil, fmt.Errorf("add_key: %w", err)
+	}
+	return map[string]interface{}{"added": added, "count": len(s.keys.keys())}, nil
+}
+
+// handleListKeys lists the key store with every key masked.
+func (s *pn532Sensor) handleListKeys() (map[string]interface{}, error) {
+	keys := s.keys.keys()
+	list := make([]interface{}, 0, len(keys))
+	for i, k := range keys {
+		list = append(list, map[string]interface{}{
+			"index":  i,
+			"source": k.source,
+			"key":    maskKeyString(hex.EncodeToString(k.key)),
+		})
+	}
+	return map[string]interface{}{"keys": list, "count": len(list)}, nil
+}
+
+func (s *pn532Sensor) handleMIFAREReadBlock(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	block, err := intArg(cmd, "block")
+	if err != nil {
+		return nil, fmt.Errorf("mifare_read_block: %w", err)
+	}
+
+	result := map[string]interface{}{"block": block, "sector": mifareBlockSector(block)}
+	err = s.runMIFARE(ctx, func(m *mifareSession) error {
+		if err := m.checkBlock(block); err != nil {
+			return err
+		}
+		var data []byte
+		unlock, err := m.withSectorKey(ctx, s.keys, mifareBlockSector(block), func() error {
+			var err error
+			data, err = m.readBlock(ctx, block)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		result["uid"] = m.tag.UID
+		result["data"] = hex.EncodeToString(data)
+		unlock.addTo(result)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mifare_read_block: %w", err)
+	}
+	return result, nil
+}
+
+// handleMIFAREWriteBlock writes 16 bytes to a data block. The manufacturer
+// block and sector trailers are refused: a bad trailer write can lock a
+// sector permanently.
+func (s *pn532Sensor) handleMIFAREWriteBlock(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	block, err := intArg(cmd, "block")
+	if err != nil {
+		return nil, fmt.Errorf("mifare_write_block: %w", err)
+	}
+	raw, _ := cmd["data"].(string)
+	data, err := hex.DecodeString(raw)
+	if err != nil || len(data) != mifareBlockSize {
+		return nil, fmt.Errorf("mifare_write_block: \"data\" must be %d hex digits", 2*mifareBlockSize)
+	}
+	if block == mifareManufacturerBlk {
+		return nil, fmt.Errorf("mifare_write_block: block 0 is the read-only manufacturer block")
+	}
+	if isMIFARETrailer(block) {
+		return nil, fmt.Errorf("mifare_write_block: block %d is a sector trailer and cannot be written", block)
+	}
+
+	result := map[string]interface{}{"block": block, "sector": mifareBlockSector(block)}
+	err = s.runMIFARE(ctx, func(m *mifareSession) error {
+		if err := m.checkBlock(block); err != nil {
+			return err
+		}
+		unlock, err := m.withSectorKey(ctx, s.keys, mifareBlockSector(block), func() error {
+			return m.writeBlock(ctx, block, data)
+		})
+		if err != nil {
+			return err
+		}
+		result["uid"] = m.tag.UID
+		result["bytes_written"] = len(data)
+		unlock.addTo(result)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mifare_write_block: %w", err)
+	}
+	return result, nil
+}
+
+// handleMIFAREReadSector reads every block of a sector, trailer included.
+// Tags return key A in the trailer as zeros.
+func (s *pn532Sensor) handleMIFAREReadSector(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	sector, err := intArg(cmd, "sector")
+	if err != nil {
+		return nil, fmt.Errorf("mifare_read_sector: %w", err)
+	}
+
+	result := map[string]interface{}{"sector": sector}
+	err = s.runMIFARE(ctx, func(m *mifareSession) error {
+		if n := mifareSectorCount(m.tag.SAK); sector >= n {
+			return fmt.Errorf("sector %d out of range for this tag (0-%d)", sector, n-1)
+		}
+		first, count := mifareSectorBlocks(sector)
+		var blocks []interface{}
+		unlock, err := m.withSectorKey(ctx, s.keys, sector, func() error {
+			blocks = make([]interface{}, 0, count)
+			for b := first; b < first+count; b++ {
+				data, err := m.readBlock(ctx, b)
+				if err != nil {
+					return err
+				}
+				blocks = append(blocks, hex.EncodeToString(data))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result["uid"] = m.tag.UID
+		result["first_block"] = first
+		result["blocks"] = blocks
+		unlock.addTo(result)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mifare_read_sector: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,214 @@
+package pn532
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"go.viam.com/rdk/logging"
+)
+
+// mifare1K is an InListPassiveTarget response for a MIFARE Classic 1K with
+// UID 63cf41e4.
+var mifare1K = []byte{0x4B, 0x01, 0x01, 0x00, 0x04, 0x08, 0x04, 0x63, 0xCF, 0x41, 0xE4}
+
+var (
+	authOK     = []byte{0x41, 0x00}
+	authFailed = []byte{0x41, 0x14}
+)
+
+func newMIFARETestSensor(t *testing.T, cfg *Config) (*pn532Sensor, *pn532lib.MockTransport) {
+	t.Helper()
+	s, mock := newTestSensorWithDevice(t, cfg)
+	keys, err := loadMIFAREKeyStore(s.cfg, "")
+	if err != nil {
+		t.Fatalf("loadMIFAREKeyStore: %v", err)
+	}
+	s.keys = keys
+	s.session = newAutoPoller(s.device, s.cfg, logging.NewTestLogger(t))
+	mock.QueueResponse(0x4A, mifare1K)
+	return s, mock
+}
+
+func TestMIFARESectorLayout(t *testing.T) {
+	tests := []struct {
+		block, sector int
+		trailer       bool
+	}{
+		{0, 0, false},
+		{3, 0, true},
+		{4, 1, false},
+		{7, 1, true},
+		{127, 31, true},
+		{128, 32, false},
+		{143, 32, true},
+		{255, 39, true},
+	}
+	for _, tt := range tests {
+		if got := mifareBlockSector(tt.block); got != tt.sector {
+			t.Errorf("mifareBlockSector(%d) = %d, want %d", tt.block, got, tt.sector)
+		}
+		if got := isMIFARETrailer(tt.block); got != tt.trailer {
+			t.Errorf("isMIFARETrailer(%d) = %v, want %v", tt.block, got, tt.trailer)
+		}
+	}
+	if first, count := mifareSectorBlocks(33); first != 144 || count != 16 {
+		t.Errorf("mifareSectorBlocks(33) = %d, %d, want 144, 16", first, count)
+	}
+}
+
+func TestMIFAREReadBlockTriesKeys(t *testing.T) {
+	s, mock := newMIFARETestSensor(t, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		MIFAREKeys: []string{"112233445566"},
+	})
+
+	balance := []byte{0x41, 0x00, 0x10, 0x27, 0, 0, 0xEF, 0xD8, 0xFF, 0xFF, 0x10, 0x27, 0, 0, 0x04, 0xFB, 0x04, 0xFB}
+	// The configured key fails as A and B; the factory default opens the
+	// sector as key B after failing as key A.
+	mock.QueueResponses(0x40, authFailed, authFailed, authFailed, authOK, balance)
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "mifare_read_block",
+		"block":  float64(4),
+	})
+	if err != nil {
+		t.Fatalf("mifare_read_block: %v", err)
+	}
+	if result["uid"] != "63cf41e4" || result["sector"] != 1 {
+		t.Errorf("uid, sector = %v, %v, want 63cf41e4, 1", result["uid"], result["sector"])
+	}
+	if result["data"] != "10270000efd8ffff1027000004fb04fb" {
+		t.Errorf("data = %v", result["data"])
+	}
+	if result["key_index"] != 1 || result["key_type"] != "B" || result["key_source"] != keySourceDefault {
+		t.Errorf("key = %v/%v/%v, want 1/B/default", result["key_index"], result["key_type"], result["key_source"])
+	}
+	if got := mock.GetCallCount(0x54); got != 3 {
+		t.Errorf("InSelect calls = %d, want 3 (one per failed key)", got)
+	}
+}
+
+func TestMIFAREReadSectorNoKey(t *testing.T) {
+	s, mock := newMIFARETestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.SetResponse(0x40, authFailed)
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action": "mifare_read_sector",
+		"sector": float64(1),
+	})
+	if err == nil || !strings.Contains(err.Error(), "no key") {
+		t.Fatalf("expected no-key error, got %v", err)
+	}
+	if got, want := mock.GetCallCount(0x40), 2*len(defaultMIFAREKeys); got != want {
+		t.Errorf("authentication attempts = %d, want %d", got, want)
+	}
+}
+
+func TestMIFAREWriteBlockRefusesTrailer(t *testing.T) {
+	s, mock := newMIFARETestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	for _, block := range []float64{0, 7} {
+		_, err := s.DoCommand(context.Background(), map[string]interface{}{
+			"action": "mifare_write_block",
+			"block":  block,
+			"data":   strings.Repeat("00", 16),
+		})
+		if err == nil {
+			t.Errorf("mifare_write_block to block %v should be refused", block)
+		}
+	}
+	if got := mock.GetCallCount(0x4A); got != 0 {
+		t.Errorf("InListPassiveTarget calls = %d, want 0", got)
+	}
+}
+
+func TestMIFAREKeyStoreSharedBetweenSensors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), mifareKeyStoreFileName)
+	var sensors []*pn532Sensor
+	for _, own := range []string{"112233445566", "665544332211"} {
+		s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", MIFAREKeys: []string{own}})
+		ks, err := openMIFAREKeyStore(s.cfg, path)
+		if err != nil {
+			t.Fatalf("openMIFAREKeyStore: %v", err)
+		}
+		s.keys = ks
+		sensors = append(sensors, s)
+	}
+
+	for i, key := range []string{"a1b2c3d4e5f6", "0f0e0d0c0b0a"} {
+		if _, err := sensors[i].DoCommand(context.Background(), map[string]interface{}{"action": "add_key", "key": key}); err != nil {
+			t.Fatalf("add_key via sensor %d: %v", i, err)
+		}
+	}
+
+	reloaded, err := loadAddedMIFAREKeys(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(reloaded.keys) != 2 {
+		t.Errorf("file holds %d keys, want both sensors' keys", len(reloaded.keys))
+	}
+	// Each sensor keeps its own mifare_keys ahead of the shared added keys.
+	for i, s := range sensors {
+		keys := s.keys.keys()
+		if len(keys) != 3+len(defaultMIFAREKeys) || keys[0].source != keySourceConfig || keys[2].source != keySourceAdded {
+			t.Errorf("sensor %d keys = %d, want its config key, both added keys, then defaults", i, len(keys))
+		}
+	}
+	if hex.EncodeToString(sensors[0].keys.keys()[0].key) == hex.EncodeToString(sensors[1].keys.keys()[0].key) {
+		t.Error("mifare_keys leaked between sensors")
+	}
+}
+
+func TestMIFAREKeyStorePersistsAddedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), mifareKeyStoreFileName)
+	cfg := &Config{MIFAREKeys: []string{"112233445566"}}
+
+	ks, err := loadMIFAREKeyStore(cfg, path)
+	if err != nil {
+		t.Fatalf("loadMIFAREKeyStore: %v", err)
+	}
+	key, _ := parseMIFAREKey("a1b2c3d4e5f6")
+	if added, err := ks.add(key); err != nil || !added {
+		t.Fatalf("add = %v, %v, want true", added, err)
+	}
+	if added, _ := ks.add(key); added {
+		t.Error("adding a key twice should report added=false")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key store file mode = %v, %v, want 0600", info, err)
+	}
+
+	reloaded, err := loadMIFAREKeyStore(cfg, path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	keys := reloaded.keys()
+	if len(keys) != 2+len(defaultMIFAREKeys) || keys[1].source != keySourceAdded {
+		t.Fatalf("keys after reload = %d, want config, added, then defaults", len(keys))
+	}
+
+	s, _ := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.keys = reloaded
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "list_keys"})
+	if err != nil {
+		t.Fatalf("list_keys: %v", err)
+	}
+	list := result["keys"].([]interface{})
+	if got := list[1].(map[string]interface{})["key"]; got != "**********f6" {
+		t.Errorf("listed key = %v, want masked", got)
+	}
+}
+
+func TestValidateMIFAREKeys(t *testing.T) {
+	cfg := &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", MIFAREKeys: []string{"ffffffffffff", "1234"}}
+	if _, _, err := cfg.Validate(""); err == nil || !strings.Contains(err.Error(), "mifare_keys[1]") {
+		t.Errorf("expected mifare_keys[1] error, got %v", err)
+	}
+}
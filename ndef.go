@@ -0,0 +1,421 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+	"github.com/ZaparooProject/go-pn532/tagops"
+)
+
+// ntagUserStartPage is the first user-memory page on NTAG21x / Type 2 tags,
+// where the NDEF TLV begins.
+const ntagUserStartPage = 4
+
+// ndefPayload is an NDEF message ready to be written. raw is the TLV-wrapped
+// encoding written directly to NTAG pages. lib is the equivalent go-pn532
+// message used for tag types that need the library's own layout (MIFARE
+// Classic MAD/sector trailers); it is nil when go-pn532 cannot represent the
+// message, e.g. a Text record in a language other than "en".
+type ndefPayload struct {
+	raw []byte
+	lib *pn532.NDEFMessage
+}
+
+// encodeNDEFTLV marshals records into an NDEF message wrapped in a Type 2
+// NDEF TLV and terminated with a Terminator TLV.
+func encodeNDEFTLV(records []*ndef.Record) ([]byte, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records to encode")
+	}
+	for i, r := range records {
+		r.SetMB(i == 0)
+		r.SetME(i == len(records)-1)
+	}
+
+	msg := &ndef.Message{Records: records}
+	body, err := msg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal NDEF message: %w", err)
+	}
+	if len(body) > 0xFFFE {
+		return nil, fmt.Errorf("NDEF message too large: %d bytes", len(body))
+	}
+
+	var out []byte
+	if len(body) < 0xFF {
+		out = append(out, 0x03, byte(len(body)))
+	} else {
+		out = append(out, 0x03, 0xFF, byte(len(body)>>8), byte(len(body)))
+	}
+	out = append(out, body...)
+	out = append(out, 0xFE)
+	return out, nil
+}
+
+// readNDEFRecords reads the NDEF message from the tag behind ops and returns
+// its records with TNF, type, and ID intact. NTAG messages are read as raw
+// pages and parsed here; other tag types go through go-pn532's ReadNDEF, whose
+// records carry no TNF or ID, so those are reconstructed from the record type.
+// A tag without an NDEF message yields no records and no error.
+func readNDEFRecords(ctx context.Context, ops *tagops.TagOperations) ([]*ndef.Record, error) {
+	if ops.GetTagType() != pn532.TagTypeNTAG {
+		msg, err := ops.ReadNDEF(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			return nil, nil
+		}
+		records := make([]*ndef.Record, 0, len(msg.Records))
+		for _, r := range msg.Records {
+			records = append(records, recordFromLibrary(r))
+		}
+		return records, nil
+	}
+
+	// Tags without an NDEF capability container (Amiibo and other
+	// proprietary formats) hold arbitrary data in user memory.
+	if cc := ops.GetCachedCapabilityContainer(); len(cc) > 0 && cc[0] != 0xE1 {
+		return nil, nil
+	}
+
+	data, err := ops.ReadBlocks(ctx, ntagUserStartPage, ntagUserStartPage+3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NDEF header: %w", err)
+	}
+	loc, err := pn532.ScanForNDEFTLV(data)
+	if errors.Is(err, pn532.ErrTLVNDEFNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate NDEF TLV: %w", err)
+	}
+	if loc.Length == 0 {
+		return nil, nil
+	}
+
+	end := loc.Offset + loc.Length
+	if end > len(data) {
+		lastPage := ntagUserStartPage + (end+3)/4 - 1
+		if lastPage > 0xFF {
+			return nil, fmt.Errorf("NDEF length %d exceeds tag memory", loc.Length)
+		}
+		data, err = ops.ReadBlocks(ctx, ntagUserStartPage, byte(lastPage))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NDEF message: %w", err)
+		}
+		if end > len(data) {
+			return nil, fmt.Errorf("NDEF length %d exceeds tag memory", loc.Length)
+		}
+	}
+
+	msg := &ndef.Message{}
+	if _, err := msg.Unmarshal(data[loc.Offset:end]); err != nil {
+		return nil, fmt.Errorf("failed to parse NDEF message: %w", err)
+	}
+	return msg.Records, nil
+}
+
+// recordFromLibrary maps a go-pn532 record back to its TNF and type. The
+// library keeps the raw payload but drops the record ID.
+func recordFromLibrary(r pn532.NDEFRecord) *ndef.Record {
+	rec := &ndef.Record{Payload: r.Payload}
+	kind := string(r.Type)
+	switch {
+	case r.Type == pn532.NDEFTypeText:
+		rec.TNF, rec.Type = ndef.TNFWellKnown, ndef.TextRecordType
+	case r.Type == pn532.NDEFTypeURI:
+		rec.TNF, rec.Type = ndef.TNFWellKnown, ndef.URIRecordType
+	case r.Type == pn532.NDEFTypeSmartPoster:
+		rec.TNF, rec.Type = ndef.TNFWellKnown, "Sp"
+	case r.Type == pn532.NDEFTypeWiFi:
+		rec.TNF, rec.Type = ndef.TNFMedia, "application/vnd.wfa.wsc"
+	case r.Type == pn532.NDEFTypeVCard:
+		rec.TNF, rec.Type = ndef.TNFMedia, "text/vcard"
+	case strings.HasPrefix(kind, "media:"):
+		rec.TNF, rec.Type = ndef.TNFMedia, strings.TrimPrefix(kind, "media:")
+	case strings.HasPrefix(kind, "uri:"):
+		rec.TNF, rec.Type = ndef.TNFAbsoluteURI, strings.TrimPrefix(kind, "uri:")
+	case strings.HasPrefix(kind, "ext:"):
+		rec.TNF, rec.Type = ndef.TNFExternal, strings.TrimPrefix(kind, "ext:")
+	default:
+		rec.TNF, rec.Type = ndef.TNFUnknown, kind
+	}
+	return rec
+}
+
+// ndefRecordReading decodes one record for the ndef_records reading. Every
+// record carries tnf, type, id, and its base64 payload; Text records add text
+// and lang, URI and Absolute URI records add the expanded uri, and media-type
+// records add mime_type.
+func ndefRecordReading(r *ndef.Record) map[string]interface{} {
+	reading := map[string]interface{}{
+		"tnf":     int(r.TNF),
+		"type":    r.Type,
+		"id":      r.ID,
+		"payload": base64.StdEncoding.EncodeToString(r.Payload),
+	}
+	switch r.TNF {
+	case ndef.TNFWellKnown:
+		switch r.Type {
+		case ndef.TextRecordType:
+			if tr, err := ndef.ParseTextRecord(r.Payload); err == nil {
+				reading["text"] = tr.Text
+				reading["lang"] = tr.Language
+			}
+		case ndef.URIRecordType:
+			if uri, err := ndef.ParseURIRecord(r.Payload); err == nil {
+				reading["uri"] = uri
+			}
+		}
+	case ndef.TNFMedia:
+		reading["mime_type"] = r.Type
+	case ndef.TNFAbsoluteURI:
+		reading["uri"] = r.Type
+	}
+	return reading
+}
+
+// firstNDEFText returns the text of the first non-empty Text record.
+func firstNDEFText(records []*ndef.Record) string {
+	for _, r := range records {
+		if r.TNF != ndef.TNFWellKnown || r.Type != ndef.TextRecordType {
+			continue
+		}
+		if tr, err := ndef.ParseTextRecord(r.Payload); err == nil && tr.Text != "" {
+			return tr.Text
+		}
+	}
+	return ""
+}
+
+// textPayload builds a single NDEF Text record payload.
+func textPayload(text, lang string) (*ndefPayload, error) {
+	raw, err := encodeNDEFTLV([]*ndef.Record{ndef.NewTextRecord(text, lang)})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ndefPayload{raw: raw}
+	if lang == "en" {
+		p.lib = &pn532.NDEFMessage{Records: []pn532.NDEFRecord{{Type: pn532.NDEFTypeText, Text: text}}}
+	}
+	return p, nil
+}
+
+// writeNDEFPayload writes p to the tag behind ops and verifies it by reading
+// it back. The caller must have exclusive device access.
+func writeNDEFPayload(ctx context.Context, ops *tagops.TagOperations, p *ndefPayload) error {
+	if ops.GetTagType() == pn532.TagTypeNTAG {
+		if err := ops.WriteBlocks(ctx, ntagUserStartPage, p.raw); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+		pages := (len(p.raw) + 3) / 4
+		readBack, err := ops.ReadBlocks(ctx, ntagUserStartPage, byte(ntagUserStartPage+pages-1))
+		if err != nil {
+			return fmt.Errorf("verification read failed: %w", err)
+		}
+		if len(readBack) < len(p.raw) || !bytes.Equal(readBack[:len(p.raw)], p.raw) {
+			return fmt.Errorf("verification failed: read-back data does not match written data")
+		}
+		return nil
+	}
+
+	if p.lib == nil {
+		return fmt.Errorf("message cannot be written to %s tags", ops.GetTagType())
+	}
+	if err := ops.WriteNDEF(ctx, p.lib); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	if _, err := ops.ReadNDEF(ctx); err != nil {
+		return fmt.Errorf("verification read failed: %w", err)
+	}
+	return nil
+}
+
+// ndefTooLargeError reports an NDEF message that does not fit in the target
+// tag's user memory. It is returned before anything is written.
+type ndefTooLargeError struct {
+	MessageBytes  int
+	CapacityBytes int
+}
+
+func (e *ndefTooLargeError) Error() string {
+	return fmt.Sprintf("NDEF message is %d bytes, exceeds tag user memory of %d bytes", e.MessageBytes, e.CapacityBytes)
+}
+
+// checkNDEFCapacity returns an ndefTooLargeError if p does not fit in
+// capacity bytes. A capacity of 0 means unknown and always passes.
+func checkNDEFCapacity(p *ndefPayload, capacity int) error {
+	if capacity > 0 && len(p.raw) > capacity {
+		return &ndefTooLargeError{MessageBytes: len(p.raw), CapacityBytes: capacity}
+	}
+	return nil
+}
+
+// ndefPayloadFromSpecs builds a payload from the JSON record list accepted by
+// write_ndef. Each record is an object with a "type" of text, uri, mime,
+// external, smart_poster, or raw, plus an optional "id":
+//
+//	{"type": "text", "text": "...", "lang": "en"}
+//	{"type": "uri", "uri": "https://..."}
+//	{"type": "mime", "mime_type": "application/json", "data": "..."}
+//	{"type": "external", "external_type": "example.com:kind", "payload": "<base64>"}
+//	{"type": "smart_poster", "uri": "https://...", "title": "...", "lang": "en"}
+//	{"type": "raw", "tnf": 4, "record_type": "example.com:kind", "payload": "<base64>"}
+//
+// mime and external records take their payload from either "data" (a UTF-8
+// string) or "payload" (base64).
+func ndefPayloadFromSpecs(specs []interface{}) (*ndefPayload, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("records must be a non-empty list")
+	}
+
+	records := make([]*ndef.Record, 0, len(specs))
+	libRecords := make([]pn532.NDEFRecord, 0, len(specs))
+	libOK := true
+
+	for i, raw := range specs {
+		spec, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record %d: must be an object", i)
+		}
+		rec, libRec, err := ndefRecordFromSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		if id, ok := spec["id"].(string); ok && id != "" {
+			rec.ID = id
+			libRec = nil
+		}
+		records = append(records, rec)
+		if libRec == nil {
+			libOK = false
+		} else {
+			libRecords = append(libRecords, *libRec)
+		}
+	}
+
+	encoded, err := encodeNDEFTLV(records)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ndefPayload{raw: encoded}
+	if libOK {
+		p.lib = &pn532.NDEFMessage{Records: libRecords}
+	}
+	return p, nil
+}
+
+// ndefRecordFromSpec converts one write_ndef record object. The second return
+// value is the go-pn532 equivalent, or nil if the library cannot encode it.
+func ndefRecordFromSpec(spec map[string]interface{}) (*ndef.Record, *pn532.NDEFRecord, error) {
+	kind, _ := spec["type"].(string)
+	switch kind {
+	case "text":
+		text, ok := spec["text"].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("text record requires \"text\"")
+		}
+		lang := stringOr(spec, "lang", "en")
+		var libRec *pn532.NDEFRecord
+		if lang == "en" {
+			libRec = &pn532.NDEFRecord{Type: pn532.NDEFTypeText, Text: text}
+		}
+		return ndef.NewTextRecord(text, lang), libRec, nil
+
+	case "uri":
+		uri, ok := spec["uri"].(string)
+		if !ok || uri == "" {
+			return nil, nil, fmt.Errorf("uri record requires \"uri\"")
+		}
+		return ndef.NewURIRecord(uri), &pn532.NDEFRecord{Type: pn532.NDEFTypeURI, URI: uri}, nil
+
+	case "mime":
+		mimeType, ok := spec["mime_type"].(string)
+		if !ok || mimeType == "" {
+			return nil, nil, fmt.Errorf("mime record requires \"mime_type\"")
+		}
+		payload, err := specPayload(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		libRec := &pn532.NDEFRecord{Type: pn532.NDEFRecordType("media:" + mimeType), Payload: payload}
+		return ndef.NewMediaRecord(mimeType, payload), libRec, nil
+
+	case "external":
+		extType, ok := spec["external_type"].(string)
+		if !ok || !strings.Contains(extType, ":") {
+			return nil, nil, fmt.Errorf("external record requires \"external_type\" in domain:type form")
+		}
+		payload, err := specPayload(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ndef.NewExternalRecord(extType, payload), nil, nil
+
+	case "smart_poster":
+		uri, ok := spec["uri"].(string)
+		if !ok || uri == "" {
+			return nil, nil, fmt.Errorf("smart_poster record requires \"uri\"")
+		}
+		inner := []*ndef.Record{ndef.NewURIRecord(uri)}
+		if title, ok := spec["title"].(string); ok && title != "" {
+			inner = append(inner, ndef.NewTextRecord(title, stringOr(spec, "lang", "en")))
+		}
+		for i, r := range inner {
+			r.SetMB(i == 0)
+			r.SetME(i == len(inner)-1)
+		}
+		body, err := (&ndef.Message{Records: inner}).Marshal()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode smart poster: %w", err)
+		}
+		return &ndef.Record{TNF: ndef.TNFWellKnown, Type: "Sp", Payload: body}, nil, nil
+
+	case "raw":
+		tnf, ok := spec["tnf"].(float64)
+		if !ok || tnf < float64(ndef.TNFEmpty) || tnf > float64(ndef.TNFUnknown) {
+			return nil, nil, fmt.Errorf("raw record requires \"tnf\" between 0 and 5")
+		}
+		recType, _ := spec["record_type"].(string)
+		payload, err := specPayload(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &ndef.Record{TNF: byte(tnf), Type: recType, Payload: payload}, nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown record type %q, must be one of text, uri, mime, external, smart_poster, raw", kind)
+	}
+}
+
+// specPayload returns a record payload from "payload" (base64) or "data"
+// (UTF-8 string). Neither being set yields an empty payload.
+func specPayload(spec map[string]interface{}) ([]byte, error) {
+	if b64, ok := spec["payload"].(string); ok {
+		payload, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 \"payload\": %w", err)
+		}
+		return payload, nil
+	}
+	if data, ok := spec["data"].(string); ok {
+		return []byte(data), nil
+	}
+	return nil, nil
+}
+
+func stringOr(spec map[string]interface{}, key, fallback string) string {
+	if v, ok := spec[key].(string); ok && v != "" {
+		return v
+	}
+	return fallback
+}
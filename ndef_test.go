@@ -0,0 +1,233 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+	"github.com/ZaparooProject/go-pn532/tagops"
+)
+
+func TestEncodeNDEFTLVShortAndLong(t *testing.T) {
+	short, err := encodeNDEFTLV([]*ndef.Record{ndef.NewTextRecord("hi", "en")})
+	if err != nil {
+		t.Fatalf("encodeNDEFTLV: %v", err)
+	}
+	// TLV: 03 len [D1 01 05 'T' 02 'e' 'n' 'h' 'i'] FE
+	want := []byte{0x03, 0x09, 0xD1, 0x01, 0x05, 'T', 0x02, 'e', 'n', 'h', 'i', 0xFE}
+	if !bytes.Equal(short, want) {
+		t.Errorf("short TLV = % X, want % X", short, want)
+	}
+
+	long, err := encodeNDEFTLV([]*ndef.Record{ndef.NewMediaRecord("application/octet-stream", make([]byte, 300))})
+	if err != nil {
+		t.Fatalf("encodeNDEFTLV: %v", err)
+	}
+	if long[0] != 0x03 || long[1] != 0xFF {
+		t.Fatalf("long TLV should use 3-byte length, got % X", long[:4])
+	}
+	bodyLen := int(long[2])<<8 | int(long[3])
+	if bodyLen != len(long)-5 {
+		t.Errorf("long TLV length = %d, want %d", bodyLen, len(long)-5)
+	}
+	if long[len(long)-1] != 0xFE {
+		t.Error("TLV should end with terminator 0xFE")
+	}
+}
+
+func TestNDEFPayloadFromSpecsAllTypes(t *testing.T) {
+	specs := []interface{}{
+		map[string]interface{}{"type": "text", "text": "hello", "lang": "fr"},
+		map[string]interface{}{"type": "uri", "uri": "https://example.com"},
+		map[string]interface{}{"type": "mime", "mime_type": "application/json", "data": `{"a":1}`},
+		map[string]interface{}{"type": "external", "external_type": "example.com:kind", "payload": "AQID"},
+		map[string]interface{}{"type": "smart_poster", "uri": "https://example.com", "title": "Example"},
+		map[string]interface{}{"type": "raw", "tnf": float64(5), "payload": "AAE="},
+	}
+
+	p, err := ndefPayloadFromSpecs(specs)
+	if err != nil {
+		t.Fatalf("ndefPayloadFromSpecs: %v", err)
+	}
+	if p.lib != nil {
+		t.Error("message with external/raw records should not have a go-pn532 representation")
+	}
+
+	var body []byte
+	if p.raw[1] == 0xFF {
+		body = p.raw[4 : len(p.raw)-1]
+	} else {
+		body = p.raw[2 : len(p.raw)-1]
+	}
+	msg := &ndef.Message{}
+	if _, err := msg.Unmarshal(body); err != nil {
+		t.Fatalf("encoded message does not parse: %v", err)
+	}
+	if len(msg.Records) != len(specs) {
+		t.Fatalf("record count = %d, want %d", len(msg.Records), len(specs))
+	}
+
+	wantTypes := []struct {
+		tnf byte
+		typ string
+	}{
+		{ndef.TNFWellKnown, "T"},
+		{ndef.TNFWellKnown, "U"},
+		{ndef.TNFMedia, "application/json"},
+		{ndef.TNFExternal, "example.com:kind"},
+		{ndef.TNFWellKnown, "Sp"},
+		{ndef.TNFUnknown, ""},
+	}
+	for i, want := range wantTypes {
+		if msg.Records[i].TNF != want.tnf || msg.Records[i].Type != want.typ {
+			t.Errorf("record %d = TNF %d type %q, want TNF %d type %q",
+				i, msg.Records[i].TNF, msg.Records[i].Type, want.tnf, want.typ)
+		}
+	}
+	if !bytes.Equal(msg.Records[3].Payload, []byte{1, 2, 3}) {
+		t.Errorf("external payload = % X, want 01 02 03", msg.Records[3].Payload)
+	}
+}
+
+func TestNDEFPayloadFromSpecsLibraryCompatible(t *testing.T) {
+	p, err := ndefPayloadFromSpecs([]interface{}{
+		map[string]interface{}{"type": "text", "text": "hello"},
+		map[string]interface{}{"type": "uri", "uri": "https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("ndefPayloadFromSpecs: %v", err)
+	}
+	if p.lib == nil || len(p.lib.Records) != 2 {
+		t.Fatal("en text + uri message should have a go-pn532 representation")
+	}
+}
+
+func TestNDEFPayloadFromSpecsInvalid(t *testing.T) {
+	cases := map[string][]interface{}{
+		"empty":          {},
+		"not object":     {"text"},
+		"unknown type":   {map[string]interface{}{"type": "bogus"}},
+		"text no text":   {map[string]interface{}{"type": "text"}},
+		"mime no type":   {map[string]interface{}{"type": "mime", "data": "x"}},
+		"external bad":   {map[string]interface{}{"type": "external", "external_type": "nocolon"}},
+		"raw bad tnf":    {map[string]interface{}{"type": "raw", "tnf": float64(9)}},
+		"raw bad base64": {map[string]interface{}{"type": "raw", "tnf": float64(5), "payload": "!!"}},
+	}
+	for name, specs := range cases {
+		if _, err := ndefPayloadFromSpecs(specs); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNDEFRecordReading(t *testing.T) {
+	text := ndef.NewTextRecord("bonjour", "fr")
+	text.ID = "greeting"
+
+	tests := []struct {
+		name   string
+		record *ndef.Record
+		want   map[string]interface{}
+	}{
+		{
+			name:   "text",
+			record: text,
+			want:   map[string]interface{}{"tnf": 1, "type": "T", "id": "greeting", "text": "bonjour", "lang": "fr"},
+		},
+		{
+			name:   "uri with prefix",
+			record: ndef.NewURIRecord("https://www.example.com/a"),
+			want:   map[string]interface{}{"tnf": 1, "type": "U", "uri": "https://www.example.com/a"},
+		},
+		{
+			name:   "mime",
+			record: ndef.NewMediaRecord("application/json", []byte(`{}`)),
+			want:   map[string]interface{}{"tnf": 2, "type": "application/json", "mime_type": "application/json", "payload": "e30="},
+		},
+		{
+			name:   "external",
+			record: ndef.NewExternalRecord("example.com:kind", []byte{0x01}),
+			want:   map[string]interface{}{"tnf": 4, "type": "example.com:kind", "payload": "AQ=="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ndefRecordReading(tt.record)
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %v, want %v", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadNDEFRecordsNTAG(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	tag := setupNTAG215Mock(mock)
+	ops := tagops.New(s.device)
+	if err := ops.InitFromDetectedTag(context.Background(), tag); err != nil {
+		t.Fatalf("InitFromDetectedTag: %v", err)
+	}
+
+	uri := ndef.NewURIRecord("https://example.com/tag")
+	uri.ID = "link"
+	raw, err := encodeNDEFTLV([]*ndef.Record{uri, ndef.NewTextRecord("hello", "en")})
+	if err != nil {
+		t.Fatalf("encodeNDEFTLV: %v", err)
+	}
+
+	// Page-by-page reads (FAST_READ disabled): a 4-page header read, then the
+	// whole TLV once its length is known.
+	mock.SetError(0x42, errors.New("FAST_READ unsupported"))
+	queuePages := func(pages int) {
+		for i := range pages {
+			resp := make([]byte, 18)
+			resp[0] = 0x41
+			if i*4 < len(raw) {
+				copy(resp[2:], raw[i*4:min(len(raw), i*4+4)])
+			}
+			mock.QueueResponse(0x40, resp)
+		}
+	}
+	queuePages(4)
+	queuePages((len(raw) + 3) / 4)
+
+	records, err := readNDEFRecords(context.Background(), ops)
+	if err != nil {
+		t.Fatalf("readNDEFRecords: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[0].ID != "link" || records[0].TNF != ndef.TNFWellKnown || records[0].Type != ndef.URIRecordType {
+		t.Errorf("record 0 = tnf %d type %q id %q", records[0].TNF, records[0].Type, records[0].ID)
+	}
+	if got := firstNDEFText(records); got != "hello" {
+		t.Errorf("firstNDEFText = %q, want hello", got)
+	}
+}
+
+func TestRecordFromLibrary(t *testing.T) {
+	tests := []struct {
+		in      pn532lib.NDEFRecordType
+		tnf     byte
+		recType string
+	}{
+		{pn532lib.NDEFTypeText, ndef.TNFWellKnown, "T"},
+		{pn532lib.NDEFTypeURI, ndef.TNFWellKnown, "U"},
+		{"media:image/png", ndef.TNFMedia, "image/png"},
+		{"uri:https://example.com", ndef.TNFAbsoluteURI, "https://example.com"},
+		{"ext:example.com:kind", ndef.TNFExternal, "example.com:kind"},
+	}
+	for _, tt := range tests {
+		rec := recordFromLibrary(pn532lib.NDEFRecord{Type: tt.in})
+		if rec.TNF != tt.tnf || rec.Type != tt.recType {
+			t.Errorf("%s: got tnf %d type %q, want tnf %d type %q", tt.in, rec.TNF, rec.Type, tt.tnf, tt.recType)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package pn532
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+)
+
+// ntagCmdFastRead reads a page range in one command. It is sent with
+// InCommunicateThru like GET_VERSION.
+const ntagCmdFastRead = 0x3A
+
+// ntagFastReadMaxPages keeps each FAST_READ response within one PN532 frame.
+const ntagFastReadMaxPages = 32
+
+// isNTAGSystemPage reports whether page holds the UID, static lock bytes,
+// capability container, dynamic lock bytes or configuration.
+func isNTAGSystemPage(layout ntagLockLayout, page int) bool {
+	return page < ntagUserStartPage || page >= ntagUserEnd(layout)
+}
+
+// ntagFastRead reads pages [first, end) with FAST_READ.
+func ntagFastRead(ctx context.Context, device *pn532.Device, first, end int) ([]byte, error) {
+	out := make([]byte, 0, (end-first)*ntagPageSize)
+	for start := first; start < end; start += ntagFastReadMaxPages {
+		last := min(start+ntagFastReadMaxPages, end) - 1
+		res, err := device.SendRawCommand(ctx, []byte{ntagCmdFastRead, byte(start), byte(last)})
+		if err != nil {
+			_ = reselectTag(ctx, device)
+			return nil, fmt.Errorf("FAST_READ of pages %d-%d failed: %w", start, last, err)
+		}
+		want := (last - start + 1) * ntagPageSize
+		if len(res) < want {
+			return nil, fmt.Errorf("FAST_READ of pages %d-%d returned %d bytes, want %d", start, last, len(res), want)
+		}
+		out = append(out, res[:want]...)
+	}
+	return out, nil
+}
+
+// pageRange reads start_page and count from a DoCommand request and checks
+// them against the tag size.
+func pageRange(cmd map[string]interface{}, layout ntagLockLayout) (start, count int, err error) {
+	if start, err = intArg(cmd, "start_page"); err != nil {
+		return 0, 0, err
+	}
+	if count, err = intArg(cmd, "count"); err != nil || count == 0 {
+		return 0, 0, fmt.Errorf("missing or invalid \"count\" field")
+	}
+	if start+count > layout.totalPages {
+		return 0, 0, fmt.Errorf("pages %d-%d out of range for %s (0-%d)", start, start+count-1, layout.variant, layout.totalPages-1)
+	}
+	return start, count, nil
+}
+
+// handleReadPages reads count pages from start_page of the NTAG or
+// Ultralight EV1 in the field, with READ or, if fast_read is set, FAST_READ.
+func (s *pn532Sensor) handleReadPages(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	fastRead, _ := cmd["fast_read"].(bool)
+
+	var result map[string]interface{}
+	err := s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		if tag.Type != pn532.TagTypeNTAG {
+			return fmt.Errorf("tag %s is %s, not NTAG or Ultralight", tag.UID, tag.Type)
+		}
+		layout, err := ntagLockLayoutFor(ctx, device)
+		if err != nil {
+			return err
+		}
+		start, count, err := pageRange(cmd, layout)
+		if err != nil {
+			return err
+		}
+		s.authenticateConfigured(ctx, device, tag, false, start+count-1)
+
+		var data []byte
+		if fastRead {
+			data, err = ntagFastRead(ctx, device, start, start+count)
+		} else {
+			data, err = ntagReadRange(ctx, device, start, start+count)
+		}
+		if err != nil {
+			return err
+		}
+
+		pages := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			pages = append(pages, hex.EncodeToString(data[i*ntagPageSize:(i+1)*ntagPageSize]))
+		}
+		result = map[string]interface{}{
+			"uid":        tag.UID,
+			"variant":    layout.variant,
+			"start_page": start,
+			"count":      count,
+			"data":       hex.EncodeToString(data),
+			"pages":      pages,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read_pages: %w", err)
+	}
+	return result, nil
+}
+
+// handleWritePages writes data, a whole number of pages, from start_page of
+// the NTAG or Ultralight EV1 in the field. UID, lock, CC and configuration
+// pages are refused unless allow_system_pages is set: lock bits and the CC
+// are one-time programmable.
+func (s *pn532Sensor) handleWritePages(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	raw, _ := cmd["data"].(string)
+	data, err := hex.DecodeString(raw)
+	if err != nil || len(data) == 0 || len(data)%ntagPageSize != 0 {
+		return nil, fmt.Errorf("write_pages: \"data\" must be hex with a multiple of %d bytes", ntagPageSize)
+	}
+	allowSystem, _ := cmd["allow_system_pages"].(bool)
+	args := map[string]interface{}{"start_page": cmd["start_page"], "count": float64(len(data) / ntagPageSize)}
+
+	var result map[string]interface{}
+	err = s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		if tag.Type != pn532.TagTypeNTAG {
+			return fmt.Errorf("tag %s is %s, not NTAG or Ultralight", tag.UID, tag.Type)
+		}
+		layout, err := ntagLockLayoutFor(ctx, device)
+		if err != nil {
+			return err
+		}
+		start, count, err := pageRange(args, layout)
+		if err != nil {
+			return err
+		}
+		if !allowSystem {
+			for page := start; page < start+count; page++ {
+				if isNTAGSystemPage(layout, page) {
+					return fmt.Errorf("page %d is a UID, lock, CC or configuration page of %s (user pages %d-%d); pass \"allow_system_pages\": true to write it",
+						page, layout.variant, ntagUserStartPage, ntagUserEnd(layout)-1)
+				}
+			}
+		}
+		s.authenticateConfigured(ctx, device, tag, true, start+count-1)
+
+		for i := 0; i < count; i++ {
+			if err := ntagWritePage(ctx, device, start+i, data[i*ntagPageSize:(i+1)*ntagPageSize]); err != nil {
+				return err
+			}
+		}
+		result = map[string]interface{}{
+			"uid":           tag.UID,
+			"variant":       layout.variant,
+			"start_page":    start,
+			"pages_written": count,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("write_pages: %w", err)
+	}
+	return result, nil
+}
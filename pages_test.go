@@ -0,0 +1,140 @@
+package pn532
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReadPages(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag213Version)
+	mock.QueueResponse(0x40, ntagPagesResponse([]byte{0x01, 0x02, 0x03, 0x04}, []byte{0x05, 0x06, 0x07, 0x08}))
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "read_pages",
+		"start_page": float64(10),
+		"count":      float64(2),
+	})
+	if err != nil {
+		t.Fatalf("read_pages: %v", err)
+	}
+	if result["data"] != "0102030405060708" || result["start_page"] != 10 || result["count"] != 2 {
+		t.Errorf("result = %v", result)
+	}
+	pages := result["pages"].([]interface{})
+	if len(pages) != 2 || pages[1] != "05060708" {
+		t.Errorf("pages = %v", pages)
+	}
+}
+
+func TestReadPagesFastRead(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag213Version)
+	res := []byte{0x43, 0x00}
+	for i := 0; i < 3*ntagPageSize; i++ {
+		res = append(res, byte(i))
+	}
+	mock.QueueResponse(0x42, res)
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "read_pages",
+		"start_page": float64(4),
+		"count":      float64(3),
+		"fast_read":  true,
+	})
+	if err != nil {
+		t.Fatalf("read_pages: %v", err)
+	}
+	if result["data"] != "000102030405060708090a0b" {
+		t.Errorf("data = %v", result["data"])
+	}
+	if got := mock.GetCallCount(0x40); got != 0 {
+		t.Errorf("InDataExchange calls = %d, want 0", got)
+	}
+}
+
+func TestReadPagesOutOfRange(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag213Version)
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "read_pages",
+		"start_page": float64(44),
+		"count":      float64(2),
+	})
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+}
+
+func TestWritePages(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	mock.QueueResponse(0x42, ntag213Version)
+	mock.SetResponse(0x40, ntagAck)
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "write_pages",
+		"start_page": float64(38),
+		"data":       "0102030405060708",
+	})
+	if err != nil {
+		t.Fatalf("write_pages: %v", err)
+	}
+	if result["pages_written"] != 2 || result["start_page"] != 38 {
+		t.Errorf("result = %v", result)
+	}
+	if got := mock.GetCallCount(0x40); got != 2 {
+		t.Errorf("InDataExchange calls = %d, want 2", got)
+	}
+}
+
+func TestWritePagesSystemPageGuard(t *testing.T) {
+	tests := []struct {
+		name  string
+		start float64
+		allow bool
+	}{
+		{"capability container", 3, false},
+		{"dynamic lock", 39, false},
+		{"allowed", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+			mock.QueueResponse(0x42, ntag213Version)
+			mock.SetResponse(0x40, ntagAck)
+
+			_, err := s.DoCommand(context.Background(), map[string]interface{}{
+				"action":             "write_pages",
+				"start_page":         tt.start,
+				"data":               "e1101200e1101200",
+				"allow_system_pages": tt.allow,
+			})
+			if tt.allow {
+				if err != nil {
+					t.Fatalf("write_pages: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "allow_system_pages") {
+				t.Fatalf("expected system page error, got %v", err)
+			}
+			if got := mock.GetCallCount(0x40); got != 0 {
+				t.Errorf("InDataExchange calls = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestWritePagesInvalidData(t *testing.T) {
+	s, _ := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":     "write_pages",
+		"start_page": float64(4),
+		"data":       "010203",
+	})
+	if err == nil || !strings.Contains(err.Error(), "multiple of 4") {
+		t.Fatalf("expected data error, got %v", err)
+	}
+}
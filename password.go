@@ -0,0 +1,479 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/tagops"
+)
+
+// NTAG21x commands, sent through InDataExchange.
+const (
+	ntagCmdRead    = 0x30
+	ntagCmdWrite   = 0xA2
+	ntagCmdPwdAuth = 0x1B
+)
+
+const (
+	ntagPageSize     = 4
+	ntagPasswordSize = 4
+	ntagPACKSize     = 2
+
+	// The configuration area is the last four pages of every NTAG21x and
+	// Ultralight EV1: CFG0 (AUTH0 in byte 3), CFG1 (ACCESS in byte 0), PWD,
+	// PACK.
+	ntagConfigPages = 4
+	// ntagAuth0Disabled in AUTH0 turns password protection off.
+	ntagAuth0Disabled = 0xFF
+	// ACCESS bits: PROT extends protection to reads, AUTHLIM limits failed
+	// PWD_AUTH attempts before the tag locks itself permanently.
+	ntagAccessProt        = 0x80
+	ntagAccessAuthLimMask = 0x07
+	maxNTAGAuthLimit      = 7
+
+	// maxRejectedPasswords bounds the UIDs remembered as having rejected
+	// ntag_password.
+	maxRejectedPasswords = 256
+)
+
+// ntagConfig is the decoded configuration area of an NTAG.
+type ntagConfig struct {
+	page      int // page of CFG0
+	cfg0      []byte
+	cfg1      []byte
+	auth0     int
+	protRead  bool
+	authLimit int
+}
+
+// protected reports whether any page is password protected.
+func (c ntagConfig) protected(totalPages int) bool {
+	return c.auth0 < totalPages
+}
+
+// parseNTAGPassword decodes a password (8 hex digits) and optional PACK (4
+// hex digits).
+func parseNTAGPassword(password, pack string) (pwd, ack []byte, err error) {
+	pwd, err = hex.DecodeString(strings.TrimSpace(password))
+	if err != nil || len(pwd) != ntagPasswordSize {
+		return nil, nil, fmt.Errorf("password must be %d hex digits", 2*ntagPasswordSize)
+	}
+	if pack == "" {
+		return pwd, nil, nil
+	}
+	ack, err = hex.DecodeString(strings.TrimSpace(pack))
+	if err != nil || len(ack) != ntagPACKSize {
+		return nil, nil, fmt.Errorf("pack must be %d hex digits", 2*ntagPACKSize)
+	}
+	return pwd, ack, nil
+}
+
+// ntagReadPages reads the four pages starting at page.
+func ntagReadPages(ctx context.Context, device *pn532.Device, page int) ([]byte, error) {
+	data, err := device.SendDataExchange(ctx, []byte{ntagCmdRead, byte(page)})
+	if err != nil {
+		return nil, fmt.Errorf("read of page %d failed: %w", page, err)
+	}
+	if len(data) < 4*ntagPageSize {
+		return nil, fmt.Errorf("read of page %d returned %d bytes, want %d", page, len(data), 4*ntagPageSize)
+	}
+	return data[:4*ntagPageSize], nil
+}
+
+func ntagWritePage(ctx context.Context, device *pn532.Device, page int, data []byte) error {
+	if _, err := device.SendDataExchange(ctx, append([]byte{ntagCmdWrite, byte(page)}, data...)); err != nil {
+		return fmt.Errorf("write of page %d failed: %w", page, err)
+	}
+	return nil
+}
+
+// ntagAuthenticate sends PWD_AUTH and returns the tag's PACK. If pack is
+// given, a tag answering with a different PACK is rejected: counterfeit tags
+// accept any password. After a failed attempt the tag is reselected so it
+// can still be read up to AUTH0.
+func ntagAuthenticate(ctx context.Context, device *pn532.Device, password, pack []byte) ([]byte, error) {
+	res, err := device.SendDataExchange(ctx, append([]byte{ntagCmdPwdAuth}, password...))
+	if err != nil {
+		_ = reselectTag(ctx, device)
+		return nil, fmt.Errorf("password rejected: %w", err)
+	}
+	if len(res) < ntagPACKSize {
+		return nil, fmt.Errorf("PWD_AUTH returned %d bytes, want %d", len(res), ntagPACKSize)
+	}
+	if pack != nil && !bytes.Equal(res[:ntagPACKSize], pack) {
+		return nil, fmt.Errorf("PACK mismatch: tag returned %X", res[:ntagPACKSize])
+	}
+	return res[:ntagPACKSize], nil
+}
+
+// readNTAGConfig reads the configuration area of a tag with totalPages pages.
+// PWD and PACK always read as zeros.
+func readNTAGConfig(ctx context.Context, device *pn532.Device, totalPages int) (ntagConfig, error) {
+	page := totalPages - ntagConfigPages
+	data, err := ntagReadPages(ctx, device, page)
+	if err != nil {
+		return ntagConfig{}, err
+	}
+	return ntagConfig{
+		page:      page,
+		cfg0:      data[0:4],
+		cfg1:      data[4:8],
+		auth0:     int(data[3]),
+		protRead:  data[4]&ntagAccessProt != 0,
+		authLimit: int(data[4] & ntagAccessAuthLimMask),
+	}, nil
+}
+
+// ntagNeedsPassword reports whether an operation on pages up to lastPage of
+// the NTAG in the field needs PWD_AUTH: AUTH0 is at or below lastPage, and the
+// operation writes or PROT protects reads as well. lastPage 0 means the last
+// user page. Tags whose layout is unknown are never authenticated.
+func ntagNeedsPassword(ctx context.Context, device *pn532.Device, write bool, lastPage int) bool {
+	layout, err := ntagLockLayoutFor(ctx, device)
+	if err != nil {
+		return false
+	}
+	if lastPage == 0 {
+		lastPage = ntagUserEnd(layout) - 1
+	}
+	cfg, err := readNTAGConfig(ctx, device, layout.totalPages)
+	if err == nil {
+		return cfg.auth0 <= lastPage && (write || cfg.protRead)
+	}
+	// The configuration area only refuses reads with PROT set, so reads and
+	// writes are protected from AUTH0 on; whether that reaches lastPage shows
+	// in reading it on its own.
+	_ = reselectTag(ctx, device)
+	if _, err := ntagFastRead(ctx, device, lastPage, lastPage+1); err != nil {
+		return true
+	}
+	return false
+}
+
+// authenticateConfigured authenticates an NTAG with ntag_password, if
+// configured, when an operation on pages up to lastPage needs it (see
+// ntagNeedsPassword). Each failed PWD_AUTH counts against AUTHLIM and can
+// disable a tag for good, so unprotected and foreign tags are never sent the
+// password and a tag that refused it is not sent it again. Failures are
+// logged; the tag is still read up to AUTH0.
+func (s *pn532Sensor) authenticateConfigured(ctx context.Context, device *pn532.Device, detectedTag *pn532.DetectedTag, write bool, lastPage int) {
+	cfg := s.config()
+	if cfg.NTAGPassword == "" || detectedTag.Type != pn532.TagTypeNTAG {
+		return
+	}
+	password, pack, err := parseNTAGPassword(cfg.NTAGPassword, cfg.NTAGPack)
+	if err != nil {
+		return // rejected by Validate
+	}
+	s.mu.RLock()
+	rejected := s.rejectedPasswords[detectedTag.UID] == cfg.NTAGPassword
+	s.mu.RUnlock()
+	if rejected {
+		s.logger.Debugw("not retrying ntag_password on a tag that rejected it", "uid", detectedTag.UID)
+		return
+	}
+	if !ntagNeedsPassword(ctx, device, write, lastPage) {
+		return
+	}
+	if _, err := ntagAuthenticate(ctx, device, password, pack); err != nil {
+		s.logger.Debugw("ntag_password authentication failed", "uid", detectedTag.UID, "error", err)
+		s.mu.Lock()
+		if s.rejectedPasswords == nil {
+			s.rejectedPasswords = make(map[string]string)
+		}
+		if len(s.rejectedPasswords) >= maxRejectedPasswords {
+			for uid := range s.rejectedPasswords {
+				delete(s.rejectedPasswords, uid)
+				break
+			}
+		}
+		s.rejectedPasswords[detectedTag.UID] = cfg.NTAGPassword
+		s.mu.Unlock()
+	}
+}
+
+// readPasswordState fills info.passwordProtected from the tag's AUTH0. If
+// reads are protected and the tag was not authenticated, the configuration
+// area cannot be read and the tag is reported as protected.
+func (s *pn532Sensor) readPasswordState(ctx context.Context, device *pn532.Device, ops *tagops.TagOperations, info *tagState) {
+	if ops == nil || ops.GetTagType() != pn532.TagTypeNTAG {
+		return
+	}
+	tagInfo, err := ops.GetTagInfo()
+	if err != nil || tagInfo.TotalPages <= ntagConfigPages {
+		return
+	}
+	cfg, err := readNTAGConfig(ctx, device, tagInfo.TotalPages)
+	if err != nil {
+		// Only a refusal from the tag says anything about protection.
+		var pnErr *pn532.PN532Error
+		if errors.As(err, &pnErr) {
+			_ = reselectTag(ctx, device)
+			info.passwordChecked = true
+			info.passwordProtected = true
+		}
+		return
+	}
+	info.passwordChecked = true
+	info.passwordProtected = cfg.protected(tagInfo.TotalPages)
+}
+
+// ntagPages checks that tag is an NTAG and returns its size in pages. A tag
+// whose capability container is read protected must be authenticated first.
+func ntagPages(ctx context.Context, device *pn532.Device, tag *pn532.DetectedTag) (int, error) {
+	if tag.Type != pn532.TagTypeNTAG {
+		return 0, fmt.Errorf("tag %s is %s, not NTAG", tag.UID, tag.Type)
+	}
+	ops := tagops.New(device)
+	if err := ops.InitFromDetectedTag(ctx, tag); err != nil {
+		return 0, fmt.Errorf("failed to initialize tag operations: %w", err)
+	}
+	info, err := ops.GetTagInfo()
+	if err != nil {
+		return 0, err
+	}
+	if info.TotalPages <= ntagConfigPages {
+		return 0, fmt.Errorf("unknown NTAG size (%d pages)", info.TotalPages)
+	}
+	return info.TotalPages, nil
+}
+
+// passwordArg reads an optional password and PACK from a DoCommand request,
+// falling back to the configured ntag_password and ntag_pack. It returns a
+// nil password if neither is set.
+func (s *pn532Sensor) passwordArg(cmd map[string]interface{}, field, packField string) (password, pack []byte, err error) {
+	raw, _ := cmd[field].(string)
+	rawPack, _ := cmd[packField].(string)
+	if raw == "" {
+		cfg := s.config()
+		raw, rawPack = cfg.NTAGPassword, cfg.NTAGPack
+	}
+	if raw == "" {
+		return nil, nil, nil
+	}
+	password, pack, err = parseNTAGPassword(raw, rawPack)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%q: %w", field, err)
+	}
+	return password, pack, nil
+}
+
+// handleNTAGSetPassword sets PWD and PACK on the NTAG in the field and
+// protects every page from auth0 on. PROT and AUTHLIM are updated in CFG1,
+// and AUTH0 is written last so that protection only starts once the password
+// is in place. A tag that is already protected needs current_password (or
+// ntag_password), which is only sent after AUTH0 shows the protection.
+func (s *pn532Sensor) handleNTAGSetPassword(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	raw, _ := cmd["password"].(string)
+	rawPack, _ := cmd["pack"].(string)
+	if rawPack == "" {
+		rawPack = "0000"
+	}
+	password, pack, err := parseNTAGPassword(raw, rawPack)
+	if err != nil {
+		return nil, fmt.Errorf("ntag_set_password: %w", err)
+	}
+	auth0 := ntagUserStartPage
+	if _, ok := cmd["auth0"]; ok {
+		if auth0, err = intArg(cmd, "auth0"); err != nil || auth0 > ntagAuth0Disabled {
+			return nil, fmt.Errorf("ntag_set_password: \"auth0\" must be a page number (0-255)")
+		}
+	}
+	authLimit := 0
+	if _, ok := cmd["auth_limit"]; ok {
+		if authLimit, err = intArg(cmd, "auth_limit"); err != nil || authLimit > maxNTAGAuthLimit {
+			return nil, fmt.Errorf("ntag_set_password: \"auth_limit\" must be between 0 and %d", maxNTAGAuthLimit)
+		}
+	}
+	protRead, _ := cmd["protect_read"].(bool)
+	current, currentPack, err := s.passwordArg(cmd, "current_password", "current_pack")
+	if err != nil {
+		return nil, fmt.Errorf("ntag_set_password: %w", err)
+	}
+
+	var result map[string]interface{}
+	err = s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		readConfig := func() (int, ntagConfig, error) {
+			totalPages, err := ntagPages(ctx, device, tag)
+			if err != nil {
+				return 0, ntagConfig{}, err
+			}
+			cfg, err := readNTAGConfig(ctx, device, totalPages)
+			return totalPages, cfg, err
+		}
+
+		// The current password is only sent once the tag shows it is
+		// protected: an unprotected tag rejects every password but the
+		// factory default, and each rejection counts against AUTHLIM. A tag
+		// that refuses to be read has PROT set.
+		if tag.Type != pn532.TagTypeNTAG {
+			return fmt.Errorf("tag %s is %s, not NTAG", tag.UID, tag.Type)
+		}
+		totalPages, cfg, err := readConfig()
+		if err != nil || cfg.protected(totalPages) {
+			if current == nil {
+				if err != nil {
+					return fmt.Errorf("%w; pass \"current_password\" if the tag is password protected", err)
+				}
+				return errors.New("tag is password protected; pass \"current_password\"")
+			}
+			readErr := err
+			if readErr != nil {
+				_ = reselectTag(ctx, device)
+			}
+			if _, err := ntagAuthenticate(ctx, device, current, currentPack); err != nil {
+				return fmt.Errorf("current password: %w", err)
+			}
+			if readErr != nil {
+				if totalPages, cfg, err = readConfig(); err != nil {
+					return err
+				}
+			}
+		}
+
+		cfg1 := bytes.Clone(cfg.cfg1)
+		cfg1[0] &^= ntagAccessProt | ntagAccessAuthLimMask
+		cfg1[0] |= byte(authLimit)
+		if protRead {
+			cfg1[0] |= ntagAccessProt
+		}
+		cfg0 := bytes.Clone(cfg.cfg0)
+		cfg0[3] = byte(auth0)
+
+		for _, w := range []struct {
+			page int
+			data []byte
+		}{
+			{cfg.page + 2, password},
+			{cfg.page + 3, append(bytes.Clone(pack), 0x00, 0x00)},
+			{cfg.page + 1, cfg1},
+			{cfg.page, cfg0},
+		} {
+			if err := ntagWritePage(ctx, device, w.page, w.data); err != nil {
+				return err
+			}
+		}
+
+		result = map[string]interface{}{
+			"uid":                tag.UID,
+			"auth0":              auth0,
+			"protect_read":       protRead,
+			"auth_limit":         authLimit,
+			"password_protected": auth0 < totalPages,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ntag_set_password: %w", err)
+	}
+	s.refreshPasswordState(result["uid"].(string), result["password_protected"].(bool))
+	return result, nil
+}
+
+// handleNTAGAuthenticate checks a password against the NTAG in the field and
+// returns its PACK.
+func (s *pn532Sensor) handleNTAGAuthenticate(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	password, pack, err := s.passwordArg(cmd, "password", "pack")
+	if err != nil {
+		return nil, fmt.Errorf("ntag_authenticate: %w", err)
+	}
+	if password == nil {
+		return nil, fmt.Errorf("ntag_authenticate: missing \"password\" and no ntag_password configured")
+	}
+
+	var result map[string]interface{}
+	err = s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		if tag.Type != pn532.TagTypeNTAG {
+			return fmt.Errorf("tag %s is %s, not NTAG", tag.UID, tag.Type)
+		}
+		ack, err := ntagAuthenticate(ctx, device, password, pack)
+		if err != nil {
+			return err
+		}
+		result = map[string]interface{}{
+			"uid":           tag.UID,
+			"authenticated": true,
+			"pack":          hex.EncodeToString(ack),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ntag_authenticate: %w", err)
+	}
+	return result, nil
+}
+
+// handleNTAGClearPassword authenticates and restores the factory settings:
+// AUTH0 0xFF, PROT and AUTHLIM cleared, PWD FFFFFFFF and PACK 0000.
+func (s *pn532Sensor) handleNTAGClearPassword(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	password, pack, err := s.passwordArg(cmd, "password", "pack")
+	if err != nil {
+		return nil, fmt.Errorf("ntag_clear_password: %w", err)
+	}
+	if password == nil {
+		return nil, fmt.Errorf("ntag_clear_password: missing \"password\" and no ntag_password configured")
+	}
+
+	var uid string
+	err = s.runOnTag(ctx, func(device *pn532.Device, tag *pn532.DetectedTag) error {
+		if tag.Type != pn532.TagTypeNTAG {
+			return fmt.Errorf("tag %s is %s, not NTAG", tag.UID, tag.Type)
+		}
+		if _, err := ntagAuthenticate(ctx, device, password, pack); err != nil {
+			return err
+		}
+		totalPages, err := ntagPages(ctx, device, tag)
+		if err != nil {
+			return err
+		}
+		cfg, err := readNTAGConfig(ctx, device, totalPages)
+		if err != nil {
+			return err
+		}
+
+		cfg0 := bytes.Clone(cfg.cfg0)
+		cfg0[3] = ntagAuth0Disabled
+		cfg1 := bytes.Clone(cfg.cfg1)
+		cfg1[0] &^= ntagAccessProt | ntagAccessAuthLimMask
+
+		for _, w := range []struct {
+			page int
+			data []byte
+		}{
+			{cfg.page, cfg0},
+			{cfg.page + 1, cfg1},
+			{cfg.page + 2, []byte{0xFF, 0xFF, 0xFF, 0xFF}},
+			{cfg.page + 3, []byte{0x00, 0x00, 0x00, 0x00}},
+		} {
+			if err := ntagWritePage(ctx, device, w.page, w.data); err != nil {
+				return err
+			}
+		}
+		uid = tag.UID
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ntag_clear_password: %w", err)
+	}
+	s.refreshPasswordState(uid, false)
+	return map[string]interface{}{"uid": uid, "password_protected": false}, nil
+}
+
+// refreshPasswordState updates the cached password_protected if uid is the
+// tag currently in the field, and forgets that the tag rejected
+// ntag_password.
+func (s *pn532Sensor) refreshPasswordState(uid string, protected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The tag's password changed, so ntag_password may now be accepted.
+	delete(s.rejectedPasswords, uid)
+	if s.state.tagPresent && s.state.uid == uid {
+		s.state.passwordChecked = true
+		s.state.passwordProtected = protected
+	}
+}
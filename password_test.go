@@ -0,0 +1,267 @@
+package pn532
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"go.viam.com/rdk/logging"
+)
+
+// ntag215 is an InListPassiveTarget response for an NTAG215 with UID
+// 04123456789abc.
+var ntag215 = []byte{0x4B, 0x01, 0x01, 0x00, 0x44, 0x00, 0x07, 0x04, 0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC}
+
+var ntagAck = []byte{0x41, 0x00}
+
+// ntagConfigResponse is a READ of the NTAG215 configuration area (page 131)
+// with the given AUTH0 and ACCESS bytes.
+func ntagConfigResponse(auth0, access byte) []byte {
+	res := make([]byte, 2+4*ntagPageSize)
+	res[0] = 0x41
+	res[2], res[5] = 0x04, auth0
+	res[6] = access
+	return res
+}
+
+func newNTAGTestSensor(t *testing.T, cfg *Config) (*pn532Sensor, *pn532lib.MockTransport) {
+	t.Helper()
+	s, mock := newTestSensorWithDevice(t, cfg)
+	s.session = newAutoPoller(s.device, s.cfg, logging.NewTestLogger(t))
+	mock.QueueResponse(0x4A, ntag215)
+	return s, mock
+}
+
+func TestNTAGSetPassword(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	setupNTAG215Mock(mock)
+	mock.QueueResponse(0x40, ntagConfigResponse(ntagAuth0Disabled, 0x00))
+	mock.SetResponse(0x40, ntagAck)
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":       "ntag_set_password",
+		"password":     "1a2b3c4d",
+		"auth0":        float64(16),
+		"auth_limit":   float64(3),
+		"protect_read": true,
+	})
+	if err != nil {
+		t.Fatalf("ntag_set_password: %v", err)
+	}
+	if result["uid"] != "04123456789abc" || result["password_protected"] != true {
+		t.Errorf("result = %v", result)
+	}
+	if result["auth0"] != 16 || result["auth_limit"] != 3 || result["protect_read"] != true {
+		t.Errorf("result = %v", result)
+	}
+	// CC and page 4 for tag init, the configuration read, then PWD, PACK,
+	// CFG1 and CFG0.
+	if got := mock.GetCallCount(0x40); got != 7 {
+		t.Errorf("InDataExchange calls = %d, want 7", got)
+	}
+}
+
+func TestNTAGSetPasswordProtectedTag(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	setupNTAG215Mock(mock)
+	mock.QueueResponse(0x40, ntagConfigResponse(0x04, 0x00))
+	mock.SetResponse(0x40, ntagAck)
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":   "ntag_set_password",
+		"password": "1a2b3c4d",
+	})
+	if err == nil || !strings.Contains(err.Error(), "current_password") {
+		t.Fatalf("expected password protected error, got %v", err)
+	}
+	if got := mock.GetCallCount(0x40); got != 3 {
+		t.Errorf("InDataExchange calls = %d, want 3 (no writes)", got)
+	}
+}
+
+func TestNTAGSetPasswordUnprotectedTagWithConfiguredPassword(t *testing.T) {
+	// ntag_password is for tags already provisioned; a factory-fresh tag
+	// would NAK it and count the attempt against AUTHLIM.
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", NTAGPassword: "99887766"})
+	setupNTAG215Mock(mock)
+	mock.QueueResponse(0x40, ntagConfigResponse(ntagAuth0Disabled, 0x00))
+	mock.SetResponse(0x40, ntagAck)
+
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":   "ntag_set_password",
+		"password": "1a2b3c4d",
+	})
+	if err != nil {
+		t.Fatalf("ntag_set_password: %v", err)
+	}
+	if result["password_protected"] != true {
+		t.Errorf("result = %v", result)
+	}
+	// CC and page 4, the configuration read and four writes; no PWD_AUTH.
+	if got := mock.GetCallCount(0x40); got != 7 {
+		t.Errorf("InDataExchange calls = %d, want 7", got)
+	}
+}
+
+func TestNTAGSetPasswordCurrentPassword(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	setupNTAG215Mock(mock)
+	mock.QueueResponse(0x40, ntagConfigResponse(0x04, 0x00))
+	mock.QueueResponse(0x40, []byte{0x41, 0x00, 0x00, 0x00})
+	mock.SetResponse(0x40, ntagAck)
+
+	if _, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"action":           "ntag_set_password",
+		"password":         "1a2b3c4d",
+		"current_password": "99887766",
+	}); err != nil {
+		t.Fatalf("ntag_set_password: %v", err)
+	}
+	// CC and page 4, the configuration read, PWD_AUTH and four writes.
+	if got := mock.GetCallCount(0x40); got != 8 {
+		t.Errorf("InDataExchange calls = %d, want 8", got)
+	}
+}
+
+func TestNTAGAuthenticatePACKMismatch(t *testing.T) {
+	s, mock := newNTAGTestSensor(t, &Config{
+		Transport:    "i2c",
+		DevicePath:   "/dev/i2c-1",
+		NTAGPassword: "1a2b3c4d",
+		NTAGPack:     "abcd",
+	})
+	mock.QueueResponse(0x40, []byte{0x41, 0x00, 0x00, 0x00})
+
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "ntag_authenticate"})
+	if err == nil || !strings.Contains(err.Error(), "PACK mismatch") {
+		t.Fatalf("expected PACK mismatch, got %v", err)
+	}
+
+	mock.QueueResponse(0x4A, ntag215)
+	mock.QueueResponse(0x40, []byte{0x41, 0x00, 0xAB, 0xCD})
+	result, err := s.DoCommand(context.Background(), map[string]interface{}{"action": "ntag_authenticate"})
+	if err != nil {
+		t.Fatalf("ntag_authenticate: %v", err)
+	}
+	if result["authenticated"] != true || result["pack"] != "abcd" {
+		t.Errorf("result = %v", result)
+	}
+}
+
+func TestOnCardDetectedPasswordProtected(t *testing.T) {
+	readNDEF := false
+	s, mock := newTestSensorWithDevice(t, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+	})
+	tag := setupNTAG215Mock(mock)
+	mock.QueueResponse(0x40, ntagConfigResponse(0x10, 0x00))
+
+	if err := s.onCardDetected(context.Background(), tag); err != nil {
+		t.Fatalf("onCardDetected returned error: %v", err)
+	}
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	if readings["password_protected"] != true {
+		t.Errorf("password_protected = %v, want true", readings["password_protected"])
+	}
+}
+
+func TestValidateNTAGPassword(t *testing.T) {
+	tests := []struct {
+		password, pack, want string
+	}{
+		{"1a2b3c", "", "8 hex digits"},
+		{"1a2b3c4d", "abc", "4 hex digits"},
+		{"", "abcd", "ntag_pack requires ntag_password"},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", NTAGPassword: tt.password, NTAGPack: tt.pack}
+		if _, _, err := cfg.Validate(""); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%q, %q) = %v, want error containing %q", tt.password, tt.pack, err, tt.want)
+		}
+	}
+}
+
+func TestAuthenticateConfiguredOnlyWhenProtected(t *testing.T) {
+	tag := &pn532lib.DetectedTag{UID: "04123456789abc", Type: pn532lib.TagTypeNTAG}
+	tests := []struct {
+		name         string
+		auth0, acc   byte
+		write        bool
+		wantPwdAuths int
+	}{
+		{"unprotected", ntagAuth0Disabled, 0x00, true, 0},
+		{"config pages only", 0x83, ntagAccessProt, true, 0},
+		{"write protected, read", 0x10, 0x00, false, 0},
+		{"write protected, write", 0x10, 0x00, true, 1},
+		{"read protected, read", 0x10, ntagAccessProt, false, 1},
+	}
+	for _, tt := range tests {
+		s, mock := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", NTAGPassword: "1a2b3c4d"})
+		mock.SelectTarget()
+		mock.QueueResponse(0x42, ntag215Version)
+		mock.QueueResponse(0x40, ntagConfigResponse(tt.auth0, tt.acc))
+		mock.QueueResponse(0x40, []byte{0x41, 0x00, 0x00, 0x00})
+
+		s.authenticateConfigured(context.Background(), s.device, tag, tt.write, 0)
+		// One READ of the configuration area, then PWD_AUTH if needed.
+		if got := mock.GetCallCount(0x40) - 1; got != tt.wantPwdAuths {
+			t.Errorf("%s: PWD_AUTH sent %d times, want %d", tt.name, got, tt.wantPwdAuths)
+		}
+	}
+}
+
+func TestAuthenticateConfiguredNoRetryAfterNAK(t *testing.T) {
+	tag := &pn532lib.DetectedTag{UID: "04123456789abc", Type: pn532lib.TagTypeNTAG}
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", NTAGPassword: "1a2b3c4d"})
+	mock.SelectTarget()
+	mock.QueueResponse(0x42, ntag215Version)
+	mock.QueueResponse(0x40, ntagConfigResponse(0x04, 0x00))
+	mock.QueueResponse(0x40, []byte{0x41, 0x01})
+	mock.QueueResponse(0x42, ntag215Version)
+	mock.QueueResponse(0x40, ntagConfigResponse(0x04, 0x00))
+
+	s.authenticateConfigured(context.Background(), s.device, tag, true, 0)
+	s.authenticateConfigured(context.Background(), s.device, tag, true, 0)
+	if got := mock.GetCallCount(0x40); got != 2 {
+		t.Errorf("InDataExchange calls = %d, want 2 (one config read, one PWD_AUTH)", got)
+	}
+	if got := mock.GetCallCount(0x42); got != 1 {
+		t.Errorf("GET_VERSION calls = %d, want 1", got)
+	}
+
+	// Setting a new password on the tag allows ntag_password again.
+	s.refreshPasswordState(tag.UID, true)
+	if _, ok := s.rejectedPasswords[tag.UID]; ok {
+		t.Error("rejection survived a password change")
+	}
+}
+
+func TestRejectedPasswordsBounded(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", NTAGPassword: "1a2b3c4d", NTAGPack: "abcd"})
+	mock.SelectTarget()
+	mock.SetResponse(0x42, ntag215Version)
+	// Every PWD_AUTH gets the configuration page back, a PACK mismatch.
+	mock.SetResponse(0x40, ntagConfigResponse(0x04, 0x00))
+
+	for i := 0; i < maxRejectedPasswords+10; i++ {
+		tag := &pn532lib.DetectedTag{UID: fmt.Sprintf("04%012x", i), Type: pn532lib.TagTypeNTAG}
+		s.authenticateConfigured(context.Background(), s.device, tag, true, 0)
+	}
+	if n := len(s.rejectedPasswords); n != maxRejectedPasswords {
+		t.Errorf("rejectedPasswords holds %d entries, want %d", n, maxRejectedPasswords)
+	}
+
+	if err := reconfigure(t, s, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", NTAGPassword: "5e6f7a8b"}); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	if n := len(s.rejectedPasswords); n != 0 {
+		t.Errorf("rejectedPasswords holds %d entries after ntag_password changed, want 0", n)
+	}
+}
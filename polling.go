@@ -1,50 +1,155 @@
 package pn532
 
+import (
+	"time"
+
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+)
+
 // tagState holds cached tag detection data, written by polling callbacks
 // under s.mu.Lock() and read by Readings() under s.mu.RLock().
 type tagState struct {
-	deviceHealthy   bool
-	tagPresent      bool
-	uid             string
-	tagType         string
-	manufacturer    string
-	isGenuine       bool
+	deviceHealthy       bool
+	reconnectAttempts   int
+	lastDisconnectError string
+	connectedSince      time.Time
+	// transport and devicePath are those the device was opened with, which
+	// transport "auto" picks by probing.
+	transport  string
+	devicePath string
+
+	tagPresent   bool
+	detectedAt   time.Time
+	uid          string
+	tagType      string
+	manufacturer string
+	isGenuine    bool
+	// genuineMethod says what isGenuine is based on; signatureHex is empty
+	// if no originality signature was read.
+	genuineMethod   string
+	signatureHex    string
+	signatureValid  bool
 	ndefText        string
 	ndefRecordCount int
+	ndefRecords     []*ndef.Record
 	ntagVariant     string
 	mifareVariant   string
 	userMemoryBytes int
+	// NTAG only; passwordChecked is false if AUTH0 could not be read.
+	passwordChecked   bool
+	passwordProtected bool
+	// NTAG 424 DNA SUN verification; sdmChecked is false if no SUN URL was
+	// read.
+	sdmChecked     bool
+	sdmValid       bool
+	sdmUID         string
+	sdmReadCounter uint32
+	sdmError       string
+	// FeliCa targets only.
+	felicaIDm         string
+	felicaPMm         string
+	felicaSystemCodes []uint16
+	// accessReason is empty when no access control is configured.
+	accessGranted bool
+	accessReason  string
+}
+
+// setTag replaces the tag fields of st with those of info, keeping the
+// device-level fields (health, reconnect bookkeeping) of st.
+func (st *tagState) setTag(info tagState) {
+	info.deviceHealthy = st.deviceHealthy
+	info.reconnectAttempts = st.reconnectAttempts
+	info.lastDisconnectError = st.lastDisconnectError
+	info.connectedSince = st.connectedSince
+	info.transport = st.transport
+	info.devicePath = st.devicePath
+	*st = info
+}
+
+// clearTag resets all tag fields, keeping the device-level fields.
+func (st *tagState) clearTag() {
+	st.setTag(tagState{})
 }
 
 func buildReadingsFromState(state *tagState) map[string]interface{} {
-	if !state.deviceHealthy {
-		return map[string]interface{}{
+	var readings map[string]interface{}
+	switch {
+	case !state.deviceHealthy:
+		readings = map[string]interface{}{
 			"status":         "connected",
 			"device_healthy": false,
 			"tag_present":    false,
 		}
-	}
-
-	if !state.tagPresent {
-		return map[string]interface{}{
+	case !state.tagPresent:
+		readings = map[string]interface{}{
 			"status":         "connected",
 			"device_healthy": true,
 			"tag_present":    false,
 		}
+	default:
+		readings = buildTagReadings(state)
+	}
+
+	readings["reconnect_attempts"] = state.reconnectAttempts
+	readings["last_disconnect_error"] = state.lastDisconnectError
+	connectedSince := ""
+	if state.deviceHealthy && !state.connectedSince.IsZero() {
+		connectedSince = state.connectedSince.UTC().Format(time.RFC3339)
 	}
+	readings["connected_since"] = connectedSince
+	readings["transport"] = state.transport
+	readings["device_path"] = state.devicePath
 
-	return map[string]interface{}{
-		"status":           "connected",
-		"device_healthy":   true,
-		"tag_present":      true,
-		"uid":              state.uid,
-		"tag_type":         state.tagType,
-		"manufacturer":     state.manufacturer,
-		"is_genuine":       state.isGenuine,
-		"ntag_variant":     state.ntagVariant,
-		"mifare_variant":   state.mifareVariant,
+	return readings
+}
+
+func buildTagReadings(state *tagState) map[string]interface{} {
+	records := make([]interface{}, 0, len(state.ndefRecords))
+	for _, r := range state.ndefRecords {
+		records = append(records, ndefRecordReading(r))
+	}
+
+	readings := map[string]interface{}{
+		"status":            "connected",
+		"device_healthy":    true,
+		"tag_present":       true,
+		"uid":               state.uid,
+		"tag_type":          state.tagType,
+		"manufacturer":      state.manufacturer,
+		"is_genuine":        state.isGenuine,
+		"genuine_method":    state.genuineMethod,
+		"ntag_variant":      state.ntagVariant,
+		"mifare_variant":    state.mifareVariant,
 		"user_memory_bytes": state.userMemoryBytes,
-		"ndef_text":        state.ndefText,
+		"ndef_text":         state.ndefText,
 		"ndef_record_count": state.ndefRecordCount,
+		"ndef_records":      records,
+	}
+	if state.signatureHex != "" {
+		readings["signature_valid"] = state.signatureValid
+		readings["signature_hex"] = state.signatureHex
+	} else if state.genuineMethod == genuineMethodUnavailable {
+		readings["signature_valid"] = false
+	}
+	if state.passwordChecked {
+		readings["password_protected"] = state.passwordProtected
+	}
+	if state.sdmChecked {
+		readings["sdm_valid"] = state.sdmValid
+		readings["sdm_uid"] = state.sdmUID
+		readings["sdm_read_counter"] = state.sdmReadCounter
+		if state.sdmError != "" {
+			readings["sdm_error"] = state.sdmError
+		}
+	}
+	if state.felicaIDm != "" {
+		readings["felica_idm"] = state.felicaIDm
+		readings["felica_pmm"] = state.felicaPMm
+		readings["felica_system_codes"] = felicaSystemCodeStrings(state.felicaSystemCodes)
+	}
+	if state.accessReason != "" {
+		readings["access_granted"] = state.accessGranted
+		readings["access_reason"] = state.accessReason
 	}
+	return readings
 }
@@ -6,7 +6,9 @@ import (
 
 func (s *pn532Sensor) Readings(_ context.Context, _ map[string]interface{}) (map[string]interface{}, error) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
+	readings := buildReadingsFromState(&s.state)
+	s.mu.RUnlock()
 
-	return buildReadingsFromState(&s.state), nil
+	addRegistryFields(s.registry, readings)
+	return readings, nil
 }
@@ -0,0 +1,161 @@
+package pn532
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+// Causes passed to sessionCancel. errSessionRestart asks the supervisor to
+// start a new polling session on the open device; errReconfigured makes it
+// close the device and connect again under the new config.
+var (
+	errSessionRestart = errors.New("polling settings changed by reconfiguration")
+	errReconfigured   = errors.New("transport or device_path changed by reconfiguration")
+)
+
+// Reconfigure applies a new config without rebuilding the sensor, so the
+// cached tag, event history and await_scan waiters survive. Settings read
+// per detection (read_ndef, ntag_password, access lists, ...) take effect on
+// the next tag. Polling settings restart the polling session on the open
+// device, and only a transport or device_path change reconnects. A new
+// registry_path or IRQ setup needs a rebuild.
+func (s *pn532Sensor) Reconfigure(_ context.Context, deps resource.Dependencies, rawConf resource.Config) error {
+	conf, err := resource.NativeConfig[*Config](rawConf)
+	if err != nil {
+		return err
+	}
+	cfg := applyConfigDefaults(conf)
+
+	s.mu.RLock()
+	old, irq, sdm, oldRelay := s.cfg, s.irq, s.sdm, s.relay
+	s.mu.RUnlock()
+
+	if registryPath(cfg) != registryPath(old) || !irqUnchanged(deps, irq, old, cfg) {
+		return resource.NewMustRebuildError(rawConf.ResourceName())
+	}
+
+	// Build everything that can fail before touching the running sensor.
+	keys, err := parseMIFAREKeys(cfg.MIFAREKeys)
+	if err != nil {
+		return err
+	}
+	if sdmChanged(old, cfg) {
+		next, err := newSDMVerifier(cfg, sdmCounterStorePath())
+		if err != nil {
+			return err
+		}
+		// Counters already seen must still be rejected as replays.
+		if next != nil && sdm != nil {
+			next.counters = sdm.counters
+		}
+		sdm = next
+	}
+	relay := oldRelay
+	if !relayUnchanged(deps, oldRelay, old, cfg) {
+		if relay, err = newDoorRelay(deps, cfg, s.logger); err != nil {
+			return err
+		}
+	}
+
+	if s.keys != nil {
+		s.keys.setConfigured(keys)
+	}
+
+	if cfg.Debug != old.Debug {
+		s.setDebug(cfg.Debug)
+	}
+
+	s.mu.Lock()
+	s.cfg, s.sdm, s.access, s.relay = cfg, sdm, newAccessPolicy(cfg), relay
+	if cfg.NTAGPassword != old.NTAGPassword {
+		s.rejectedPasswords = nil
+	}
+	var cause error
+	switch {
+	case cfg.Transport != old.Transport || cfg.DevicePath != old.DevicePath:
+		s.cfgGen++
+		cause = errReconfigured
+	case pollingChanged(old, cfg):
+		cause = errSessionRestart
+	}
+	if cause != nil && s.sessionCancel != nil {
+		s.sessionCancel(cause)
+	}
+	s.mu.Unlock()
+
+	// onCardDetected triggers the relay under s.mu, so a replaced one gets
+	// no new pulses; let a running one finish.
+	if relay != oldRelay {
+		oldRelay.wait()
+	}
+
+	if cause != nil {
+		s.logger.Infow("reconfigured", "restart", cause)
+	}
+	return nil
+}
+
+// pollingChanged reports whether the settings baked into a polling session
+// differ.
+func pollingChanged(old, cfg *Config) bool {
+	return cfg.PollIntervalMs != old.PollIntervalMs ||
+		cfg.CardRemovalTimeoutMs != old.CardRemovalTimeoutMs ||
+		cfg.PollMode != old.PollMode ||
+		!slices.Equal(cfg.AutopollTargets, old.AutopollTargets) ||
+		cfg.AutopollPeriodMs != old.AutopollPeriodMs ||
+		cfg.AutopollCount != old.AutopollCount
+}
+
+func sdmChanged(old, cfg *Config) bool {
+	return cfg.SDMMetaReadKey != old.SDMMetaReadKey ||
+		cfg.SDMFileReadKey != old.SDMFileReadKey ||
+		cfg.SDMPICCDataParam != old.SDMPICCDataParam ||
+		cfg.SDMCMACParam != old.SDMCMACParam
+}
+
+// relayUnchanged reports whether relay, built for old, still serves cfg: the
+// same relay settings on the same board instance.
+func relayUnchanged(deps resource.Dependencies, relay *doorRelay, old, cfg *Config) bool {
+	if cfg.RelayPin != old.RelayPin || cfg.RelayPulseMs != old.RelayPulseMs {
+		return false
+	}
+	if relay == nil {
+		return true
+	}
+	if cfg.Board != old.Board {
+		return false
+	}
+	b, err := board.FromDependencies(deps, cfg.Board)
+	return err == nil && b == relay.board
+}
+
+// setDebug raises the logger to debug level, or returns it to the level it
+// had without debug.
+func (s *pn532Sensor) setDebug(debug bool) {
+	if debug {
+		s.logger.SetLevel(logging.DEBUG)
+		return
+	}
+	s.logger.SetLevel(s.baseLogLevel)
+}
+
+// irqUnchanged reports whether irq, built for old, still serves cfg: the same
+// IRQ settings on the same board instance.
+func irqUnchanged(deps resource.Dependencies, irq *irqGate, old, cfg *Config) bool {
+	if cfg.IRQPin != old.IRQPin {
+		return false
+	}
+	if irq == nil {
+		return true
+	}
+	if cfg.IRQFallbackMs != old.IRQFallbackMs || cfg.Board != old.Board {
+		return false
+	}
+	b, err := board.FromDependencies(deps, cfg.Board)
+	return err == nil && b == irq.board
+}
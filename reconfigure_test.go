@@ -0,0 +1,234 @@
+package pn532
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"go.viam.com/rdk/components/board"
+	sensor "go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+// newRunningTestSensor returns a sensor with a mock device, a polling session
+// and the reconnect supervisor running, as NewPn532 leaves it.
+func newRunningTestSensor(t *testing.T, cfg *Config) *pn532Sensor {
+	t.Helper()
+	s, _ := newTestSensorWithDevice(t, cfg)
+	s.connect = func(context.Context) (*pn532lib.Device, error) {
+		t.Error("unexpected reconnect")
+		return nil, errors.New("unexpected reconnect")
+	}
+	s.startSession(s.device)
+	s.supervisorWg.Add(1)
+	go s.superviseConnection()
+	t.Cleanup(func() { _ = s.Close(context.Background()) })
+	return s
+}
+
+func reconfigure(t *testing.T, s *pn532Sensor, cfg *Config) error {
+	t.Helper()
+	return s.Reconfigure(context.Background(), nil, resource.Config{
+		Name:                "test",
+		API:                 sensor.API,
+		Model:               Model,
+		ConvertedAttributes: cfg,
+	})
+}
+
+func (s *pn532Sensor) currentSession() (tagPoller, *pn532lib.Device) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.session, s.device
+}
+
+func TestReconfigureSoftSettings(t *testing.T) {
+	s := newRunningTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.mu.Lock()
+	s.events.add(tagEvent{kind: eventDetected, uid: "04aabbcc"})
+	s.mu.Unlock()
+	sess, device := s.currentSession()
+
+	readNDEF := false
+	err := reconfigure(t, s, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+		AllowUIDs:  []string{"04AABBCC"},
+		MIFAREKeys: []string{"a0a1a2a3a4a5"},
+	})
+	if err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if gotSess, gotDevice := s.currentSession(); gotSess != sess || gotDevice != device {
+		t.Error("soft settings restarted the session or device")
+	}
+	if *s.config().ReadNDEF {
+		t.Error("read_ndef was not applied")
+	}
+	if granted, _ := s.access.evaluate("04aabbcc"); !granted {
+		t.Error("allow_uids was not applied")
+	}
+	if keys := s.keys.keys(); keys[0].source != keySourceConfig {
+		t.Errorf("first key source = %v, want mifare_keys", keys[0].source)
+	}
+	if _, ok := s.events.last(eventDetected); !ok {
+		t.Error("event history was lost")
+	}
+}
+
+func TestReconfigurePollIntervalRestartsSession(t *testing.T) {
+	s := newRunningTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	sess, device := s.currentSession()
+	s.mu.RLock()
+	since := s.state.connectedSince
+	s.mu.RUnlock()
+
+	if err := reconfigure(t, s, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", PollIntervalMs: 100}); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+
+	ok := waitFor(t, 3*time.Second, func() bool {
+		gotSess, _ := s.currentSession()
+		return gotSess != nil && gotSess != sess
+	})
+	if !ok {
+		t.Fatal("polling session was not restarted")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.device != device {
+		t.Error("session restart reopened the device")
+	}
+	if !s.state.deviceHealthy || !s.state.connectedSince.Equal(since) {
+		t.Errorf("device state changed: healthy=%v connected_since=%v", s.state.deviceHealthy, s.state.connectedSince)
+	}
+	if _, ok := s.events.last(eventDeviceDisconnected); ok {
+		t.Error("session restart recorded a disconnect")
+	}
+}
+
+func TestReconfigureDevicePathReconnects(t *testing.T) {
+	s := newRunningTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	_, device := s.currentSession()
+
+	var connects atomic.Int32
+	s.mu.Lock()
+	s.connect = func(context.Context) (*pn532lib.Device, error) {
+		connects.Add(1)
+		return newMockDevice(t), nil
+	}
+	s.mu.Unlock()
+
+	if err := reconfigure(t, s, &Config{Transport: "i2c", DevicePath: "/dev/i2c-2"}); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+
+	ok := waitFor(t, 3*time.Second, func() bool {
+		sess, got := s.currentSession()
+		return sess != nil && got != nil && got != device
+	})
+	if !ok {
+		t.Fatal("sensor did not reconnect after device_path changed")
+	}
+	if n := connects.Load(); n != 1 {
+		t.Errorf("connect called %d times, want 1", n)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.state.deviceHealthy {
+		t.Error("device should be healthy after reconnecting")
+	}
+	if _, ok := s.events.last(eventDeviceDisconnected); ok {
+		t.Error("reconfiguration recorded a disconnect")
+	}
+}
+
+func TestReconfigureRequiresRebuild(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{"registry_path", &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", RegistryPath: "/tmp/tags.json"}},
+		{"irq_pin", &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", Board: "board", IRQPin: "22"}},
+	}
+	for _, tt := range tests {
+		if err := reconfigure(t, s, tt.cfg); !resource.IsMustRebuildError(err) {
+			t.Errorf("%s: Reconfigure = %v, want a must-rebuild error", tt.name, err)
+		}
+	}
+	if s.config().RegistryPath != "" {
+		t.Error("a rejected config was applied")
+	}
+}
+
+func TestReconfigureDebug(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	s.logger.SetLevel(logging.INFO)
+	s.baseLogLevel = logging.INFO
+
+	for _, debug := range []bool{true, false} {
+		if err := reconfigure(t, s, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", Debug: debug}); err != nil {
+			t.Fatalf("Reconfigure: %v", err)
+		}
+		want := logging.INFO
+		if debug {
+			want = logging.DEBUG
+		}
+		if got := s.logger.GetLevel(); got != want {
+			t.Errorf("debug=%v: log level = %v, want %v", debug, got, want)
+		}
+	}
+}
+
+// fakeBoard hands out fakePins by name.
+type fakeBoard struct {
+	board.Board
+}
+
+func (fakeBoard) GPIOPinByName(string) (board.GPIOPin, error) {
+	return &fakePin{}, nil
+}
+
+func TestReconfigureReusesRelay(t *testing.T) {
+	b := &fakeBoard{}
+	deps := resource.Dependencies{board.Named("board"): b}
+	cfg := &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", Board: "board", RelayPin: "16", AllowUIDs: []string{"04aabbcc"}}
+	s := newTestSensor(t, cfg)
+	relay, err := newDoorRelay(deps, s.cfg, s.logger)
+	if err != nil {
+		t.Fatalf("newDoorRelay: %v", err)
+	}
+	s.relay = relay
+
+	reconfigureWith := func(cfg *Config) {
+		t.Helper()
+		if err := s.Reconfigure(context.Background(), deps, resource.Config{
+			Name: "test", API: sensor.API, Model: Model, ConvertedAttributes: cfg,
+		}); err != nil {
+			t.Fatalf("Reconfigure: %v", err)
+		}
+	}
+
+	// An access list change leaves the relay alone.
+	next := *cfg
+	next.AllowUIDs = []string{"04aabbcc", "04ddeeff"}
+	reconfigureWith(&next)
+	if s.relay != relay {
+		t.Error("relay was rebuilt although its settings did not change")
+	}
+
+	next.RelayPulseMs = 1000
+	reconfigureWith(&next)
+	if s.relay == relay || s.relay.pulse != time.Second {
+		t.Error("relay was not rebuilt for a new relay_pulse_ms")
+	}
+}
@@ -0,0 +1,200 @@
+package pn532
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// registryFileName is the registry file created in $VIAM_MODULE_DATA when
+// registry_path is not configured.
+const registryFileName = "tag_registry.json"
+
+// registeredTag is a registry entry, stored as JSON.
+type registeredTag struct {
+	UID          string                 `json:"uid"`
+	Label        string                 `json:"label"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	RegisteredAt time.Time              `json:"registered_at"`
+}
+
+func (t registeredTag) toMap() map[string]interface{} {
+	metadata := t.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"uid":           t.UID,
+		"label":         t.Label,
+		"metadata":      metadata,
+		"registered_at": t.RegisteredAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// tagRegistry maps tag UIDs to labels and user metadata. It has its own lock
+// so that file writes never hold s.mu. Entries are persisted to path after
+// every change; an empty path keeps the registry in memory only.
+type tagRegistry struct {
+	mu   sync.RWMutex
+	path string
+	tags map[string]registeredTag // keyed by lowercase UID
+}
+
+// registryPath returns the configured registry file, falling back to the
+// module data directory. It returns "" if neither is available.
+func registryPath(cfg *Config) string {
+	if cfg.RegistryPath != "" {
+		return cfg.RegistryPath
+	}
+	if dir := os.Getenv("VIAM_MODULE_DATA"); dir != "" {
+		return filepath.Join(dir, registryFileName)
+	}
+	return ""
+}
+
+// registries shares one tagRegistry per file among the sensors in this
+// process.
+var registries storeCache[tagRegistry]
+
+// openTagRegistry returns the registry at path, shared with every other
+// sensor in this process using the same file.
+func openTagRegistry(path string) (*tagRegistry, error) {
+	return registries.open(path, loadTagRegistry)
+}
+
+// loadTagRegistry reads the registry at path. A missing file yields an empty
+// registry; a file that cannot be parsed is an error so it is never
+// overwritten.
+func loadTagRegistry(path string) (*tagRegistry, error) {
+	r := &tagRegistry{path: path, tags: map[string]registeredTag{}}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tag registry: %w", err)
+	}
+
+	var tags []registeredTag
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("failed to parse tag registry %s: %w", path, err)
+	}
+	for _, t := range tags {
+		r.tags[strings.ToLower(t.UID)] = t
+	}
+	return r, nil
+}
+
+// get returns the entry for uid. A nil registry has no entries.
+func (r *tagRegistry) get(uid string) (registeredTag, bool) {
+	if r == nil {
+		return registeredTag{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tags[strings.ToLower(uid)]
+	return t, ok
+}
+
+// list returns all entries sorted by UID.
+func (r *tagRegistry) list() []registeredTag {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedLocked()
+}
+
+// register adds or replaces the entry for t.UID and persists the registry.
+func (r *tagRegistry) register(t registeredTag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strings.ToLower(t.UID)
+	prev, existed := r.tags[key]
+	r.tags[key] = t
+	if err := r.saveLocked(); err != nil {
+		if existed {
+			r.tags[key] = prev
+		} else {
+			delete(r.tags, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// unregister removes the entry for uid and persists the registry. It reports
+// whether an entry existed.
+func (r *tagRegistry) unregister(uid string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strings.ToLower(uid)
+	prev, existed := r.tags[key]
+	if !existed {
+		return false, nil
+	}
+	delete(r.tags, key)
+	if err := r.saveLocked(); err != nil {
+		r.tags[key] = prev
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *tagRegistry) sortedLocked() []registeredTag {
+	tags := make([]registeredTag, 0, len(r.tags))
+	for _, t := range r.tags {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].UID < tags[j].UID })
+	return tags
+}
+
+// saveLocked writes the registry to path with writeFileAtomic, so a crash
+// mid-write never leaves a truncated registry.
+func (r *tagRegistry) saveLocked() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.sortedLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tag registry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create tag registry directory: %w", err)
+	}
+	if err := writeFileAtomic(r.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write tag registry: %w", err)
+	}
+	return nil
+}
+
+// addRegistryFields adds label and metadata to tag readings when the tag's
+// UID is registered.
+func addRegistryFields(r *tagRegistry, readings map[string]interface{}) {
+	uid, ok := readings["uid"].(string)
+	if !ok || uid == "" {
+		return
+	}
+	t, ok := r.get(uid)
+	if !ok {
+		return
+	}
+	entry := t.toMap()
+	readings["label"] = entry["label"]
+	readings["metadata"] = entry["metadata"]
+}
@@ -0,0 +1,114 @@
+package pn532
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTagRegistryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry", "tags.json")
+
+	r, err := loadTagRegistry(path)
+	if err != nil {
+		t.Fatalf("loadTagRegistry: %v", err)
+	}
+	if err := r.register(registeredTag{
+		UID:          "04abcdef",
+		Label:        "wrench",
+		Metadata:     map[string]interface{}{"bin": "A3"},
+		RegisteredAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.register(registeredTag{UID: "04000001", Label: "hammer", RegisteredAt: time.Now()}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if removed, err := r.unregister("04000001"); err != nil || !removed {
+		t.Fatalf("unregister = %v, %v; want true, nil", removed, err)
+	}
+
+	reloaded, err := loadTagRegistry(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	tags := reloaded.list()
+	if len(tags) != 1 {
+		t.Fatalf("got %d tags after reload, want 1", len(tags))
+	}
+	got, ok := reloaded.get("04ABCDEF")
+	if !ok || got.Label != "wrench" || got.Metadata["bin"] != "A3" {
+		t.Errorf("get = %+v, %v; want wrench with bin A3", got, ok)
+	}
+}
+
+func TestTagRegistryCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadTagRegistry(path); err == nil {
+		t.Fatal("loadTagRegistry should fail on a corrupt file")
+	}
+}
+
+func TestRegistryPath(t *testing.T) {
+	t.Setenv("VIAM_MODULE_DATA", "/data/module")
+
+	if got := registryPath(&Config{RegistryPath: "/etc/tags.json"}); got != "/etc/tags.json" {
+		t.Errorf("registryPath with registry_path = %q", got)
+	}
+	if got := registryPath(&Config{}); got != filepath.Join("/data/module", registryFileName) {
+		t.Errorf("registryPath from VIAM_MODULE_DATA = %q", got)
+	}
+}
+
+func TestTagRegistrySharedBetweenSensors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), registryFileName)
+	var sensors []*pn532Sensor
+	for range 2 {
+		s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", RegistryPath: path})
+		r, err := openTagRegistry(path)
+		if err != nil {
+			t.Fatalf("openTagRegistry: %v", err)
+		}
+		s.registry = r
+		sensors = append(sensors, s)
+	}
+
+	for i, uid := range []string{"04000001", "04000002"} {
+		if _, err := sensors[i].DoCommand(context.Background(), map[string]interface{}{
+			"action": "register_tag",
+			"uid":    uid,
+			"label":  "tool",
+		}); err != nil {
+			t.Fatalf("register_tag via sensor %d: %v", i, err)
+		}
+	}
+
+	reloaded, err := loadTagRegistry(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if tags := reloaded.list(); len(tags) != 2 {
+		t.Errorf("file holds %d tags, want both sensors' registrations", len(tags))
+	}
+	if _, ok := sensors[0].registry.get("04000002"); !ok {
+		t.Error("a tag registered through one sensor is missing from the other")
+	}
+}
+
+func TestRegistryCommandsWithoutRegistry(t *testing.T) {
+	s := newTestSensor(t, &Config{Transport: "i2c", DevicePath: "/dev/i2c-1"})
+	for _, cmd := range []map[string]interface{}{
+		{"action": "list_tags"},
+		{"action": "get_tag", "uid": "04abcdef"},
+	} {
+		if _, err := s.DoCommand(context.Background(), cmd); err == nil || !strings.Contains(err.Error(), "not available") {
+			t.Errorf("DoCommand(%v) = %v, want registry not available", cmd, err)
+		}
+	}
+}
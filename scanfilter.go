@@ -0,0 +1,110 @@
+package pn532
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+)
+
+// scanFilter holds the optional await_scan filters. A detection must pass
+// every filter that is set. require_removal_first depends on the event
+// sequence rather than the detection itself and is applied by the caller.
+type scanFilter struct {
+	uids     map[string]bool
+	tagTypes map[string]bool
+	ndefText *regexp.Regexp
+
+	// excludeUID is set by require_different_uid to the UID of the tag in the
+	// field, or else the last detected tag, when the call began.
+	excludeUID string
+}
+
+// parseScanFilter reads the filter fields of an await_scan command.
+func parseScanFilter(cmd map[string]interface{}) (*scanFilter, error) {
+	f := &scanFilter{}
+
+	uids, err := stringList(cmd, "uids")
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) > 0 {
+		f.uids = make(map[string]bool, len(uids))
+		for _, uid := range uids {
+			f.uids[strings.ToLower(uid)] = true
+		}
+	}
+
+	tagTypes, err := stringList(cmd, "tag_types")
+	if err != nil {
+		return nil, err
+	}
+	if len(tagTypes) > 0 {
+		f.tagTypes = make(map[string]bool, len(tagTypes))
+		for _, tt := range tagTypes {
+			f.tagTypes[strings.ToUpper(tt)] = true
+		}
+	}
+
+	if pattern, ok := cmd["ndef_text_regex"].(string); ok && pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid \"ndef_text_regex\": %w", err)
+		}
+		f.ndefText = re
+	}
+
+	return f, nil
+}
+
+// stringList reads an optional list of strings from cmd.
+func stringList(cmd map[string]interface{}, key string) ([]string, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%q must be a list of strings", key)
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a list of strings", key)
+		}
+		out = append(out, str)
+	}
+	return out, nil
+}
+
+// matches reports whether the detection in snap passes the filter.
+func (f *scanFilter) matches(snap *tagState) bool {
+	if f.uids != nil && !f.uids[strings.ToLower(snap.uid)] {
+		return false
+	}
+	if f.tagTypes != nil && !f.tagTypes[strings.ToUpper(snap.tagType)] {
+		return false
+	}
+	if f.ndefText != nil && !f.matchesNDEFText(snap) {
+		return false
+	}
+	if f.excludeUID != "" && strings.EqualFold(snap.uid, f.excludeUID) {
+		return false
+	}
+	return true
+}
+
+// matchesNDEFText reports whether any Text record on the tag matches.
+func (f *scanFilter) matchesNDEFText(snap *tagState) bool {
+	for _, r := range snap.ndefRecords {
+		if r.TNF != ndef.TNFWellKnown || r.Type != ndef.TextRecordType {
+			continue
+		}
+		if tr, err := ndef.ParseTextRecord(r.Payload); err == nil && f.ndefText.MatchString(tr.Text) {
+			return true
+		}
+	}
+	return false
+}
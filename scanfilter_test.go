@@ -0,0 +1,68 @@
+package pn532
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+)
+
+func TestScanFilterMatches(t *testing.T) {
+	snap := &tagState{
+		tagPresent:  true,
+		detectedAt:  time.Now(),
+		uid:         "04abcdef",
+		tagType:     "NTAG",
+		ndefRecords: []*ndef.Record{ndef.NewURIRecord("https://example.com"), ndef.NewTextRecord("tool:wrench-12", "en")},
+	}
+
+	tests := []struct {
+		name string
+		cmd  map[string]interface{}
+		want bool
+	}{
+		{"no filters", map[string]interface{}{}, true},
+		{"uid allowed", map[string]interface{}{"uids": []interface{}{"04ABCDEF", "04000000"}}, true},
+		{"uid not allowed", map[string]interface{}{"uids": []interface{}{"04000000"}}, false},
+		{"tag type allowed", map[string]interface{}{"tag_types": []interface{}{"ntag"}}, true},
+		{"tag type not allowed", map[string]interface{}{"tag_types": []interface{}{"MIFARE"}}, false},
+		{"ndef text matches second record", map[string]interface{}{"ndef_text_regex": "^tool:wrench-\\d+$"}, true},
+		{"ndef text does not match", map[string]interface{}{"ndef_text_regex": "^tool:hammer"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseScanFilter(tt.cmd)
+			if err != nil {
+				t.Fatalf("parseScanFilter: %v", err)
+			}
+			if got := f.matches(snap); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanFilterDifferentUID(t *testing.T) {
+	snap := &tagState{tagPresent: true, detectedAt: time.Now(), uid: "04abcdef", tagType: "NTAG"}
+
+	f := &scanFilter{excludeUID: "04ABCDEF"}
+	if f.matches(snap) {
+		t.Error("require_different_uid should reject the excluded uid")
+	}
+	f.excludeUID = "04000000"
+	if !f.matches(snap) {
+		t.Error("require_different_uid should accept other uids")
+	}
+}
+
+func TestParseScanFilterInvalid(t *testing.T) {
+	for _, cmd := range []map[string]interface{}{
+		{"uids": "04abcdef"},
+		{"tag_types": []interface{}{1}},
+		{"ndef_text_regex": "("},
+	} {
+		if _, err := parseScanFilter(cmd); err == nil {
+			t.Errorf("parseScanFilter(%v) should fail", cmd)
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package pn532
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+)
+
+// NFC Forum Type 4 Tag NDEF application, selected by name, and its
+// capability container file.
+var type4NDEFAppName = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+
+const (
+	type4CCFileID = 0xE103
+	// type4MaxRead keeps every READ BINARY response within one PN532 frame.
+	type4MaxRead = 0xF0
+	// type4NDEFFileTLV is the CC TLV describing the NDEF file.
+	type4NDEFFileTLV = 0x04
+)
+
+const (
+	sdmKeySize      = 16
+	sdmPICCDataSize = 16
+	sdmMACSize      = 8
+	sdmUIDSize      = 7
+
+	// PICCDataTag flags: UID mirrored, SDMReadCtr mirrored, UID length in
+	// the low nibble.
+	sdmTagUIDMirror = 0x80
+	sdmTagCtrMirror = 0x40
+	sdmTagUIDLength = 0x0F
+)
+
+// Default names of the URL query parameters carrying the mirrored PICCData
+// and SDMMAC.
+const (
+	defaultSDMPICCDataParam = "picc_data"
+	defaultSDMCMACParam     = "cmac"
+)
+
+// sdmCounterFileName is the file in $VIAM_MODULE_DATA holding the last
+// accepted SDMReadCtr of every tag.
+const sdmCounterFileName = "sdm_counters.json"
+
+// errSDMReplay is reported when a tag presents a counter that is not newer
+// than one already accepted for its UID.
+var errSDMReplay = errors.New("SDMReadCtr not greater than last seen, possible replay")
+
+// parseSDMKey decodes an AES-128 key given as 32 hex digits.
+func parseSDMKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != sdmKeySize {
+		return nil, fmt.Errorf("must be %d hex digits", 2*sdmKeySize)
+	}
+	return key, nil
+}
+
+// sdmVerifier checks NTAG 424 DNA Secure Dynamic Messaging (SUN) URLs: the
+// PICCData mirrored into the URL is decrypted with the SDMMetaReadKey and
+// the SDMMAC checked with a session key derived from the SDMFileReadKey.
+type sdmVerifier struct {
+	metaReadKey []byte
+	fileReadKey []byte
+	piccParam   string
+	cmacParam   string
+	counters    *sdmCounterStore
+}
+
+// sdmMessage is the content of a verified SUN URL.
+type sdmMessage struct {
+	uid     []byte
+	counter uint32
+}
+
+// newSDMVerifier returns nil if no SDM keys are configured.
+func newSDMVerifier(cfg *Config, counterPath string) (*sdmVerifier, error) {
+	if cfg.SDMMetaReadKey == "" {
+		return nil, nil
+	}
+	metaKey, err := parseSDMKey(cfg.SDMMetaReadKey)
+	if err != nil {
+		return nil, fmt.Errorf("sdm_meta_read_key: %w", err)
+	}
+	fileKey, err := parseSDMKey(cfg.SDMFileReadKey)
+	if err != nil {
+		return nil, fmt.Errorf("sdm_file_read_key: %w", err)
+	}
+	counters, err := openSDMCounterStore(counterPath)
+	if err != nil {
+		return nil, err
+	}
+	v := &sdmVerifier{
+		metaReadKey: metaKey,
+		fileReadKey: fileKey,
+		piccParam:   cfg.SDMPICCDataParam,
+		cmacParam:   cfg.SDMCMACParam,
+		counters:    counters,
+	}
+	if v.piccParam == "" {
+		v.piccParam = defaultSDMPICCDataParam
+	}
+	if v.cmacParam == "" {
+		v.cmacParam = defaultSDMCMACParam
+	}
+	return v, nil
+}
+
+// verifyURL decrypts the PICCData of a SUN URL and checks its SDMMAC. The
+// MAC input must be empty, i.e. the tag configured with SDMMACInputOffset
+// equal to SDMMACOffset.
+func (v *sdmVerifier) verifyURL(rawURL string) (sdmMessage, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return sdmMessage{}, fmt.Errorf("invalid URL: %w", err)
+	}
+	q := u.Query()
+	piccHex, cmacHex := q.Get(v.piccParam), q.Get(v.cmacParam)
+	if piccHex == "" || cmacHex == "" {
+		return sdmMessage{}, fmt.Errorf("URL has no %s and %s parameters", v.piccParam, v.cmacParam)
+	}
+	encPICC, err := hex.DecodeString(piccHex)
+	if err != nil || len(encPICC) != sdmPICCDataSize {
+		return sdmMessage{}, fmt.Errorf("%s must be %d hex digits", v.piccParam, 2*sdmPICCDataSize)
+	}
+	mac, err := hex.DecodeString(cmacHex)
+	if err != nil || len(mac) != sdmMACSize {
+		return sdmMessage{}, fmt.Errorf("%s must be %d hex digits", v.cmacParam, 2*sdmMACSize)
+	}
+
+	msg, err := decryptPICCData(v.metaReadKey, encPICC)
+	if err != nil {
+		return sdmMessage{}, err
+	}
+	want, err := sdmMAC(v.fileReadKey, msg, nil)
+	if err != nil {
+		return sdmMessage{}, err
+	}
+	if subtle.ConstantTimeCompare(mac, want) != 1 {
+		return msg, fmt.Errorf("SDMMAC mismatch")
+	}
+	return msg, nil
+}
+
+// decryptPICCData decrypts the 16-byte PICCData (AES-128 CBC, zero IV):
+// PICCDataTag, UID, SDMReadCtr (LSB first) and padding. Both the UID and
+// the counter must be mirrored.
+func decryptPICCData(key, enc []byte) (sdmMessage, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return sdmMessage{}, err
+	}
+	plain := make([]byte, sdmPICCDataSize)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(plain, enc)
+
+	tag := plain[0]
+	if tag&sdmTagUIDMirror == 0 || tag&sdmTagCtrMirror == 0 || int(tag&sdmTagUIDLength) != sdmUIDSize {
+		return sdmMessage{}, fmt.Errorf("invalid PICCDataTag 0x%02X, wrong sdm_meta_read_key or UID and counter not mirrored", tag)
+	}
+	ctr := plain[1+sdmUIDSize:]
+	return sdmMessage{
+		uid:     plain[1 : 1+sdmUIDSize],
+		counter: uint32(ctr[0]) | uint32(ctr[1])<<8 | uint32(ctr[2])<<16,
+	}, nil
+}
+
+// sdmMAC computes the truncated SDMMAC of input: an AES-CMAC under the
+// session key derived from the SDMFileReadKey, UID and counter (SV2),
+// keeping the odd-indexed bytes.
+func sdmMAC(fileReadKey []byte, msg sdmMessage, input []byte) ([]byte, error) {
+	sv2 := []byte{0x3C, 0xC3, 0x00, 0x01, 0x00, 0x80}
+	sv2 = append(sv2, msg.uid...)
+	sv2 = append(sv2, byte(msg.counter), byte(msg.counter>>8), byte(msg.counter>>16))
+	sessionKey, err := aesCMAC(fileReadKey, sv2)
+	if err != nil {
+		return nil, err
+	}
+	full, err := aesCMAC(sessionKey, input)
+	if err != nil {
+		return nil, err
+	}
+	mac := make([]byte, 0, sdmMACSize)
+	for i := 1; i < len(full); i += 2 {
+		mac = append(mac, full[i])
+	}
+	return mac, nil
+}
+
+// aesCMAC computes the AES-CMAC of msg (RFC 4493).
+func aesCMAC(key, msg []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+	k1 := cmacDouble(l)
+	k2 := cmacDouble(k1)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n > 0 && len(msg)%aes.BlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, aes.BlockSize)
+	copy(last, msg[(n-1)*aes.BlockSize:])
+	if complete {
+		subtle.XORBytes(last, last, k1)
+	} else {
+		last[len(msg)-(n-1)*aes.BlockSize] = 0x80
+		subtle.XORBytes(last, last, k2)
+	}
+
+	x := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x, x, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x, x)
+	}
+	subtle.XORBytes(x, x, last)
+	block.Encrypt(x, x)
+	return x, nil
+}
+
+// cmacDouble multiplies b by x in GF(2^128), deriving a CMAC subkey.
+func cmacDouble(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := 0; i < len(b)-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[len(b)-1] = b[len(b)-1] << 1
+	if b[0]&0x80 != 0 {
+		out[len(b)-1] ^= 0x87
+	}
+	return out
+}
+
+// sdmCounterStore holds the last accepted SDMReadCtr per UID, so a recorded
+// SUN URL cannot be presented again. It is persisted to path after every
+// change; an empty path keeps it in memory only.
+type sdmCounterStore struct {
+	mu       sync.Mutex
+	path     string
+	counters map[string]uint32 // keyed by lowercase UID
+}
+
+// sdmCounterStorePath returns the counter file in the module data directory,
+// or "" if there is none.
+func sdmCounterStorePath() string {
+	if dir := os.Getenv("VIAM_MODULE_DATA"); dir != "" {
+		return filepath.Join(dir, sdmCounterFileName)
+	}
+	return ""
+}
+
+// sdmCounterFiles shares one sdmCounterStore per file among the sensors in
+// this process.
+var sdmCounterFiles storeCache[sdmCounterStore]
+
+// openSDMCounterStore returns the counters at path, shared with every other
+// sensor in this process using the same file, so a URL accepted by one
+// reader is a replay on all of them.
+func openSDMCounterStore(path string) (*sdmCounterStore, error) {
+	return sdmCounterFiles.open(path, loadSDMCounterStore)
+}
+
+// loadSDMCounterStore reads the counters at path. A file that cannot be
+// parsed is an error so it is never overwritten.
+func loadSDMCounterStore(path string) (*sdmCounterStore, error) {
+	cs := &sdmCounterStore{path: path, counters: map[string]uint32{}}
+	if path == "" {
+		return cs, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SDM counters: %w", err)
+	}
+	if err := json.Unmarshal(data, &cs.counters); err != nil {
+		return nil, fmt.Errorf("failed to parse SDM counters %s: %w", path, err)
+	}
+	return cs, nil
+}
+
+// advance records counter for uid. It returns errSDMReplay, leaving the
+// store unchanged, unless counter is greater than the last one accepted.
+// A failure to persist is returned after the counter has been recorded in
+// memory.
+func (cs *sdmCounterStore) advance(uid string, counter uint32) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	key := strings.ToLower(uid)
+	if last, ok := cs.counters[key]; ok && counter <= last {
+		return errSDMReplay
+	}
+	cs.counters[key] = counter
+	return cs.saveLocked()
+}
+
+// saveLocked writes the counters to path with writeFileAtomic.
+func (cs *sdmCounterStore) saveLocked() error {
+	if cs.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(cs.counters, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SDM counters: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cs.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create SDM counter directory: %w", err)
+	}
+	if err := writeFileAtomic(cs.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write SDM counters: %w", err)
+	}
+	return nil
+}
+
+// type4Select sends an ISO 7816-4 SELECT with the given P1/P2.
+func type4Select(ctx context.Context, device *pn532.Device, p1, p2 byte, id []byte) error {
+	apdu := append([]byte{0x00, 0xA4, p1, p2, byte(len(id))}, id...)
+	if p2 == 0x00 {
+		apdu = append(apdu, 0x00)
+	}
+	_, sw, err := sendAPDU(ctx, device, apdu)
+	if err != nil {
+		return err
+	}
+	if sw != 0x9000 {
+		return fmt.Errorf("SELECT %X failed with status %04X", id, sw)
+	}
+	return nil
+}
+
+// type4ReadBinary reads n bytes of the selected file from offset.
+func type4ReadBinary(ctx context.Context, device *pn532.Device, offset, n int) ([]byte, error) {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		chunk := min(n-len(out), type4MaxRead)
+		pos := offset + len(out)
+		data, sw, err := sendAPDU(ctx, device, []byte{0x00, 0xB0, byte(pos >> 8), byte(pos), byte(chunk)})
+		if err != nil {
+			return nil, err
+		}
+		if sw != 0x9000 {
+			return nil, fmt.Errorf("READ BINARY at %d failed with status %04X", pos, sw)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("READ BINARY at %d returned no data", pos)
+		}
+		out = append(out, data...)
+	}
+	return out[:n], nil
+}
+
+// readType4NDEF reads the NDEF message of an NFC Forum Type 4 Tag: the NDEF
+// application is selected by name, the capability container names the NDEF
+// file, and the file starts with the 2-byte message length (NLEN).
+func readType4NDEF(ctx context.Context, device *pn532.Device) ([]*ndef.Record, error) {
+	if err := type4Select(ctx, device, 0x04, 0x00, type4NDEFAppName); err != nil {
+		return nil, fmt.Errorf("failed to select NDEF application: %w", err)
+	}
+	if err := type4Select(ctx, device, 0x00, 0x0C, []byte{type4CCFileID >> 8, type4CCFileID & 0xFF}); err != nil {
+		return nil, fmt.Errorf("failed to select capability container: %w", err)
+	}
+	// CCLEN, mapping version, MLe, MLc, then the NDEF File Control TLV:
+	// T, L, file ID, max size, read and write access.
+	cc, err := type4ReadBinary(ctx, device, 0, 15)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capability container: %w", err)
+	}
+	if cc[7] != type4NDEFFileTLV || cc[8] < 6 {
+		return nil, fmt.Errorf("capability container has no NDEF file control TLV")
+	}
+	fileID := cc[9:11]
+	maxSize := int(cc[11])<<8 | int(cc[12])
+
+	if err := type4Select(ctx, device, 0x00, 0x0C, fileID); err != nil {
+		return nil, fmt.Errorf("failed to select NDEF file: %w", err)
+	}
+	nlen, err := type4ReadBinary(ctx, device, 0, 2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NDEF length: %w", err)
+	}
+	length := int(nlen[0])<<8 | int(nlen[1])
+	if length == 0 {
+		return nil, nil
+	}
+	if length > maxSize-2 {
+		return nil, fmt.Errorf("NDEF length %d exceeds file size %d", length, maxSize)
+	}
+	data, err := type4ReadBinary(ctx, device, 2, length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NDEF message: %w", err)
+	}
+
+	msg := &ndef.Message{}
+	if _, err := msg.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("failed to parse NDEF message: %w", err)
+	}
+	return msg.Records, nil
+}
+
+// firstNDEFURI returns the expanded URI of the first URI or Absolute URI
+// record.
+func firstNDEFURI(records []*ndef.Record) string {
+	for _, r := range records {
+		if uri, ok := ndefRecordReading(r)["uri"].(string); ok && uri != "" {
+			return uri
+		}
+	}
+	return ""
+}
+
+// readSDM reads the NDEF message of an ISO-DEP tag and verifies its SUN URL
+// when SDM keys are configured. The caller must have exclusive device access.
+func (s *pn532Sensor) readSDM(ctx context.Context, device *pn532.Device, detectedTag *pn532.DetectedTag, info *tagState) {
+	s.mu.RLock()
+	sdm, cfg := s.sdm, s.cfg
+	s.mu.RUnlock()
+	if sdm == nil || !isISODEP(detectedTag) {
+		return
+	}
+	records, err := readType4NDEF(ctx, device)
+	if err != nil {
+		s.logger.Debugw("failed to read Type 4 NDEF", "uid", detectedTag.UID, "error", err)
+		return
+	}
+	if cfg.ReadNDEF != nil && *cfg.ReadNDEF && info.ndefRecords == nil {
+		info.ndefRecords = records
+		info.ndefRecordCount = len(records)
+		info.ndefText = firstNDEFText(records)
+	}
+	uri := firstNDEFURI(records)
+	if uri == "" {
+		return
+	}
+
+	info.sdmChecked = true
+	msg, err := sdm.verifyURL(uri)
+	if msg.uid != nil {
+		info.sdmUID = hex.EncodeToString(msg.uid)
+		info.sdmReadCounter = msg.counter
+	}
+	// A tag with random ID enabled answers anticollision with a 4-byte
+	// random UID; otherwise it must match the mirrored one.
+	if err == nil && len(detectedTag.UIDBytes) == sdmUIDSize && !bytes.Equal(detectedTag.UIDBytes, msg.uid) {
+		err = fmt.Errorf("mirrored UID %s does not match tag UID %s", info.sdmUID, detectedTag.UID)
+	}
+	if err == nil {
+		err = sdm.counters.advance(info.sdmUID, msg.counter)
+		if err != nil && !errors.Is(err, errSDMReplay) {
+			s.logger.Warnw("failed to persist SDM counter", "uid", info.sdmUID, "error", err)
+			err = nil
+		}
+	}
+	if err != nil {
+		info.sdmError = err.Error()
+		s.logger.Warnw("SUN verification failed", "uid", detectedTag.UID, "url", uri, "error", err)
+		return
+	}
+	info.sdmValid = true
+}
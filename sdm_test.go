@@ -0,0 +1,250 @@
+package pn532
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+	"github.com/ZaparooProject/go-pn532/pkg/ndef"
+)
+
+// sunURL is the SUN example of NXP AN12196: UID 04DE5F1EACC040 and
+// SDMReadCtr 61 under all-zero SDMMetaReadKey and SDMFileReadKey.
+const sunURL = "https://choose.url.com/ntag424?picc_data=EF963FF7828658A599F3041510671E88&cmac=94EED9EE65337086"
+
+const zeroSDMKey = "00000000000000000000000000000000"
+
+func TestAESCMAC(t *testing.T) {
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	msg, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411")
+	// RFC 4493 section 4.
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+	}
+	for _, tt := range tests {
+		mac, err := aesCMAC(key, msg[:tt.n])
+		if err != nil {
+			t.Fatalf("aesCMAC: %v", err)
+		}
+		if got := hex.EncodeToString(mac); got != tt.want {
+			t.Errorf("CMAC of %d bytes = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func newTestSDMVerifier(t *testing.T, cfg *Config) *sdmVerifier {
+	t.Helper()
+	v, err := newSDMVerifier(cfg, "")
+	if err != nil {
+		t.Fatalf("newSDMVerifier: %v", err)
+	}
+	return v
+}
+
+func TestSDMVerifyURL(t *testing.T) {
+	v := newTestSDMVerifier(t, &Config{SDMMetaReadKey: zeroSDMKey, SDMFileReadKey: zeroSDMKey})
+	msg, err := v.verifyURL(sunURL)
+	if err != nil {
+		t.Fatalf("verifyURL: %v", err)
+	}
+	if got := hex.EncodeToString(msg.uid); got != "04de5f1eacc040" || msg.counter != 61 {
+		t.Errorf("uid, counter = %s, %d, want 04de5f1eacc040, 61", got, msg.counter)
+	}
+
+	if _, err := v.verifyURL(strings.Replace(sunURL, "cmac=94", "cmac=95", 1)); err == nil || !strings.Contains(err.Error(), "SDMMAC mismatch") {
+		t.Errorf("altered CMAC: got %v, want SDMMAC mismatch", err)
+	}
+	if _, err := v.verifyURL("https://choose.url.com/ntag424"); err == nil {
+		t.Error("URL without SDM parameters should be rejected")
+	}
+
+	wrongMeta := newTestSDMVerifier(t, &Config{SDMMetaReadKey: strings.Repeat("11", sdmKeySize), SDMFileReadKey: zeroSDMKey})
+	if _, err := wrongMeta.verifyURL(sunURL); err == nil || !strings.Contains(err.Error(), "PICCDataTag") {
+		t.Errorf("wrong meta read key: got %v, want PICCDataTag error", err)
+	}
+	wrongFile := newTestSDMVerifier(t, &Config{SDMMetaReadKey: zeroSDMKey, SDMFileReadKey: strings.Repeat("11", sdmKeySize)})
+	if _, err := wrongFile.verifyURL(sunURL); err == nil || !strings.Contains(err.Error(), "SDMMAC mismatch") {
+		t.Errorf("wrong file read key: got %v, want SDMMAC mismatch", err)
+	}
+
+	custom := newTestSDMVerifier(t, &Config{
+		SDMMetaReadKey:   zeroSDMKey,
+		SDMFileReadKey:   zeroSDMKey,
+		SDMPICCDataParam: "e",
+		SDMCMACParam:     "c",
+	})
+	if _, err := custom.verifyURL("https://choose.url.com/ntag424?e=EF963FF7828658A599F3041510671E88&c=94EED9EE65337086"); err != nil {
+		t.Errorf("custom parameter names: %v", err)
+	}
+}
+
+func TestSDMCounterStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), sdmCounterFileName)
+	cs, err := loadSDMCounterStore(path)
+	if err != nil {
+		t.Fatalf("loadSDMCounterStore: %v", err)
+	}
+	if err := cs.advance("04DE5F1EACC040", 61); err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	for _, ctr := range []uint32{61, 60} {
+		if err := cs.advance("04de5f1eacc040", ctr); !errors.Is(err, errSDMReplay) {
+			t.Errorf("advance(%d) = %v, want replay", ctr, err)
+		}
+	}
+
+	reloaded, err := loadSDMCounterStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if err := reloaded.advance("04de5f1eacc040", 61); !errors.Is(err, errSDMReplay) {
+		t.Errorf("counter not persisted: advance(61) = %v", err)
+	}
+	if err := reloaded.advance("04de5f1eacc040", 62); err != nil {
+		t.Errorf("advance(62): %v", err)
+	}
+}
+
+// apduResponse wraps response data and a status word in an InDataExchange
+// reply.
+func apduResponse(sw uint16, data ...byte) []byte {
+	res := append([]byte{0x41, 0x00}, data...)
+	return append(res, byte(sw>>8), byte(sw))
+}
+
+// queueType4NDEF queues the replies to readType4NDEF for a Type 4 Tag
+// holding message in file E104.
+func queueType4NDEF(mock *pn532lib.MockTransport, message []byte) {
+	mock.QueueResponse(0x40, apduResponse(0x9000))
+	mock.QueueResponse(0x40, apduResponse(0x9000))
+	mock.QueueResponse(0x40, apduResponse(0x9000, 0x00, 0x17, 0x20, 0x01, 0x00, 0x00, 0xFF, 0x04, 0x06, 0xE1, 0x04, 0x01, 0x00, 0x00, 0x00))
+	mock.QueueResponse(0x40, apduResponse(0x9000))
+	mock.QueueResponse(0x40, apduResponse(0x9000, byte(len(message)>>8), byte(len(message))))
+	mock.QueueResponse(0x40, apduResponse(0x9000, message...))
+}
+
+func sdmDetectedTag() *pn532lib.DetectedTag {
+	uid := []byte{0x04, 0xDE, 0x5F, 0x1E, 0xAC, 0xC0, 0x40}
+	return &pn532lib.DetectedTag{
+		UID:      hex.EncodeToString(uid),
+		UIDBytes: uid,
+		Type:     pn532lib.TagTypeUnknown,
+		SAK:      0x20,
+	}
+}
+
+func TestOnCardDetectedSDM(t *testing.T) {
+	s, mock := newTestSensorWithDevice(t, &Config{Transport: "uart", DevicePath: "/dev/ttyUSB0", PollMode: pollModeAutopoll})
+	s.sdm = newTestSDMVerifier(t, &Config{SDMMetaReadKey: zeroSDMKey, SDMFileReadKey: zeroSDMKey})
+	mock.SelectTarget()
+
+	message, err := (&ndef.Message{Records: []*ndef.Record{ndef.NewURIRecord(sunURL)}}).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// GetVersion identifies an NTAG 424 DNA and Read_Sig is refused, then
+	// the NDEF file is read; the second time the same counter is presented.
+	for i, wantValid := range []bool{true, false} {
+		queueISODEPVersion(mock, 0x04, 0x11)
+		mock.QueueResponse(0x40, apduResponse(0x91AE))
+		queueType4NDEF(mock, message)
+
+		if err := s.onCardDetected(context.Background(), sdmDetectedTag()); err != nil {
+			t.Fatalf("onCardDetected returned error: %v", err)
+		}
+		readings, err := s.Readings(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("Readings returned error: %v", err)
+		}
+		if readings["sdm_valid"] != wantValid {
+			t.Errorf("read %d: sdm_valid = %v, want %v (sdm_error %v)", i, readings["sdm_valid"], wantValid, readings["sdm_error"])
+		}
+		if readings["sdm_uid"] != "04de5f1eacc040" || readings["sdm_read_counter"] != uint32(61) {
+			t.Errorf("read %d: sdm_uid, sdm_read_counter = %v, %v", i, readings["sdm_uid"], readings["sdm_read_counter"])
+		}
+		if readings["ndef_record_count"] != 1 {
+			t.Errorf("read %d: ndef_record_count = %v, want 1", i, readings["ndef_record_count"])
+		}
+	}
+	readings, _ := s.Readings(context.Background(), nil)
+	if msg, _ := readings["sdm_error"].(string); !strings.Contains(msg, "replay") {
+		t.Errorf("sdm_error = %q, want replay", msg)
+	}
+}
+
+func TestSDMCountersSharedBetweenSensors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), sdmCounterFileName)
+	message, err := (&ndef.Message{Records: []*ndef.Record{ndef.NewURIRecord(sunURL)}}).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// Both readers start before either has accepted a URL.
+	var sensors []*pn532Sensor
+	var mocks []*pn532lib.MockTransport
+	for i := range 2 {
+		s, mock := newTestSensorWithDevice(t, &Config{Transport: "uart", DevicePath: fmt.Sprintf("/dev/ttyUSB%d", i), PollMode: pollModeAutopoll})
+		if s.sdm, err = newSDMVerifier(&Config{SDMMetaReadKey: zeroSDMKey, SDMFileReadKey: zeroSDMKey}, path); err != nil {
+			t.Fatalf("newSDMVerifier: %v", err)
+		}
+		mock.SelectTarget()
+		sensors = append(sensors, s)
+		mocks = append(mocks, mock)
+	}
+
+	// The URL accepted by the first reader is a replay on the second.
+	for i, wantValid := range []bool{true, false} {
+		queueISODEPVersion(mocks[i], 0x04, 0x11)
+		mocks[i].QueueResponse(0x40, apduResponse(0x91AE))
+		queueType4NDEF(mocks[i], message)
+		if err := sensors[i].onCardDetected(context.Background(), sdmDetectedTag()); err != nil {
+			t.Fatalf("onCardDetected returned error: %v", err)
+		}
+		readings, err := sensors[i].Readings(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("Readings returned error: %v", err)
+		}
+		if readings["sdm_valid"] != wantValid {
+			t.Errorf("sensor %d: sdm_valid = %v, want %v (sdm_error %v)", i, readings["sdm_valid"], wantValid, readings["sdm_error"])
+		}
+	}
+
+	// A save by the second reader keeps the first reader's counter.
+	if err := sensors[1].sdm.counters.advance("04aabbccddeeff", 5); err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	reloaded, err := loadSDMCounterStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(reloaded.counters) != 2 {
+		t.Errorf("file holds counters %v, want both readers' tags", reloaded.counters)
+	}
+}
+
+func TestValidateSDMKeys(t *testing.T) {
+	tests := []struct {
+		meta, file, want string
+	}{
+		{"0011", zeroSDMKey, "sdm_meta_read_key"},
+		{zeroSDMKey, "", "sdm_file_read_key"},
+		{"", zeroSDMKey, "sdm_meta_read_key"},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Transport: "i2c", DevicePath: "/dev/i2c-1", SDMMetaReadKey: tt.meta, SDMFileReadKey: tt.file}
+		if _, _, err := cfg.Validate(""); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%q, %q) = %v, want error containing %q", tt.meta, tt.file, err, tt.want)
+		}
+	}
+}
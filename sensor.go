@@ -24,21 +24,48 @@ func init() {
 }
 
 type pn532Sensor struct {
-	resource.AlwaysRebuild
-
-	mu      sync.RWMutex
-	name    resource.Name
-	logger  logging.Logger
-	cfg     *Config
-	device  *pn532.Device
-	session    *polling.Session
-	state      tagState
-	scanNotify chan tagState
-
-	cancelCtx  context.Context
-	cancelFunc func()
-	sessionWg  sync.WaitGroup
-	closed     bool
+	mu       sync.RWMutex
+	name     resource.Name
+	logger   logging.Logger
+	cfg      *Config
+	device   *pn532.Device
+	session  tagPoller
+	state    tagState
+	events   eventLog
+	registry *tagRegistry
+	keys     *mifareKeyStore
+	sdm      *sdmVerifier
+	access   *accessPolicy
+	relay    *doorRelay
+	irq      *irqGate
+	// rejectedPasswords maps the UID of each tag that refused ntag_password
+	// to the password it refused. It holds at most maxRejectedPasswords
+	// entries and is cleared when ntag_password changes.
+	rejectedPasswords map[string]string
+	// baseLogLevel is the logger's level when debug is off.
+	baseLogLevel logging.Level
+
+	// connect opens the PN532 for the current config and records the
+	// transport and path used in state. It wraps connectDevice in production
+	// and is swapped out in tests.
+	connect func(ctx context.Context) (*pn532.Device, error)
+	// sessionExited carries the error from a polling session that stopped
+	// while the sensor was still open, waking the reconnect supervisor.
+	sessionExited chan error
+	// sessionCancel stops the running polling session with a cause; see
+	// Reconfigure.
+	sessionCancel context.CancelCauseFunc
+	// cfgGen counts reconfigurations that changed transport or device_path,
+	// and deviceGen is the cfgGen the current device was opened under. A
+	// device from an older generation is never polled.
+	cfgGen    uint64
+	deviceGen uint64
+
+	cancelCtx    context.Context
+	cancelFunc   func()
+	sessionWg    sync.WaitGroup
+	supervisorWg sync.WaitGroup
+	closed       bool
 }
 
 func newPn532Sensor(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (sensor.Sensor, error) {
@@ -67,6 +94,9 @@ func applyConfigDefaults(conf *Config) *Config {
 	if cfg.ConnectTimeoutSec <= 0 {
 		cfg.ConnectTimeoutSec = defaultConnectTimeoutSec
 	}
+	if cfg.PollMode == "" {
+		cfg.PollMode = pollModeSession
+	}
 	if cfg.ReadNDEF == nil {
 		readNDEF := true
 		cfg.ReadNDEF = &readNDEF
@@ -74,90 +104,209 @@ func applyConfigDefaults(conf *Config) *Config {
 	return &cfg
 }
 
-func NewPn532(ctx context.Context, _ resource.Dependencies, name resource.Name, conf *Config, logger logging.Logger) (sensor.Sensor, error) {
+func NewPn532(ctx context.Context, deps resource.Dependencies, name resource.Name, conf *Config, logger logging.Logger) (sensor.Sensor, error) {
 	cancelCtx, cancelFunc := context.WithCancel(context.Background())
 
 	cfg := applyConfigDefaults(conf)
 
-	device, err := connectDevice(ctx, cfg, logger)
+	baseLogLevel := logger.GetLevel()
+	if cfg.Debug {
+		logger.SetLevel(logging.DEBUG)
+	}
+
+	registry, err := openTagRegistry(registryPath(cfg))
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+	if registry.path == "" {
+		logger.Warn("no registry_path or VIAM_MODULE_DATA, tag registry will not persist across restarts")
+	}
+
+	keys, err := openMIFAREKeyStore(cfg, mifareKeyStorePath())
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+
+	sdm, err := newSDMVerifier(cfg, sdmCounterStorePath())
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+
+	relay, err := newDoorRelay(deps, cfg, logger)
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+	irq, err := newIRQGate(deps, cfg, logger)
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+
+	device, endpoint, err := connectDevice(ctx, cfg, logger)
 	if err != nil {
 		cancelFunc()
 		return nil, err
 	}
 
 	s := &pn532Sensor{
-		name:       name,
-		logger:     logger,
-		cfg:        cfg,
-		cancelCtx:  cancelCtx,
-		cancelFunc: cancelFunc,
-		scanNotify: make(chan tagState, 1),
+		name:          name,
+		logger:        logger,
+		baseLogLevel:  baseLogLevel,
+		cfg:           cfg,
+		cancelCtx:     cancelCtx,
+		cancelFunc:    cancelFunc,
+		registry:      registry,
+		keys:          keys,
+		sdm:           sdm,
+		access:        newAccessPolicy(cfg),
+		relay:         relay,
+		irq:           irq,
+		sessionExited: make(chan error, 1),
+		state:         tagState{transport: endpoint.transport, devicePath: endpoint.path},
+	}
+	s.connect = func(ctx context.Context) (*pn532.Device, error) {
+		device, endpoint, err := connectDevice(ctx, s.config(), s.logger)
+		if err == nil {
+			s.mu.Lock()
+			s.state.transport, s.state.devicePath = endpoint.transport, endpoint.path
+			s.mu.Unlock()
+		}
+		return device, err
 	}
 
 	s.startSession(device)
+
+	s.supervisorWg.Add(1)
+	go s.superviseConnection()
+
 	return s, nil
 }
 
-// startSession wires up the polling session and goroutine for a connected device.
+// startSession wires up the tag poller for the configured poll_mode and its
+// goroutine for a connected device.
 func (s *pn532Sensor) startSession(device *pn532.Device) {
 	s.mu.Lock()
-	s.device = device
+	if s.closed {
+		s.mu.Unlock()
+		if err := device.Close(); err != nil {
+			s.logger.Errorw("error closing PN532 device", "error", err)
+		}
+		return
+	}
+	if s.device != device {
+		s.device = device
+		openDevices.claim(s.state.devicePath)
+		s.state.connectedSince = time.Now()
+	}
+	if s.deviceGen != s.cfgGen {
+		// device_path or transport changed while this device was being
+		// opened; hand it straight back to the supervisor.
+		s.mu.Unlock()
+		s.signalSessionExited(errReconfigured)
+		return
+	}
 	s.state.deviceHealthy = true
+	cfg := s.cfg
+	sessCtx, sessCancel := context.WithCancelCause(s.cancelCtx)
+	s.sessionCancel = sessCancel
 	s.mu.Unlock()
 
-	sess := polling.NewSession(device, &polling.Config{
-		PollInterval:       time.Duration(s.cfg.PollIntervalMs) * time.Millisecond,
-		CardRemovalTimeout: time.Duration(s.cfg.CardRemovalTimeoutMs) * time.Millisecond,
-	})
-	sess.SetOnCardDetected(s.onCardDetected)
-	sess.SetOnCardRemoved(s.onCardRemoved)
-	sess.SetOnDeviceDisconnected(s.onDeviceDisconnected)
+	var poller tagPoller
+	var sess *polling.Session
+	if cfg.PollMode == pollModeAutopoll {
+		ap := newAutoPoller(device, cfg, s.logger)
+		ap.SetOnCardDetected(s.onCardDetected)
+		ap.SetOnCardRemoved(s.onCardRemoved)
+		ap.SetOnDeviceDisconnected(s.onDeviceDisconnected)
+		poller = ap
+	} else {
+		sess = polling.NewSession(device, &polling.Config{
+			PollInterval:       time.Duration(cfg.PollIntervalMs) * time.Millisecond,
+			CardRemovalTimeout: time.Duration(cfg.CardRemovalTimeoutMs) * time.Millisecond,
+		})
+		sess.SetOnCardDetected(s.onCardDetected)
+		sess.SetOnCardRemoved(s.onCardRemoved)
+		sess.SetOnDeviceDisconnected(s.onDeviceDisconnected)
+		poller = sess
+	}
 
 	s.mu.Lock()
-	s.session = sess
+	s.session = poller
 	s.mu.Unlock()
 
+	// The IRQ gate lives exactly as long as this session's polling loop.
+	// Validate rejects irq_pin in autopoll mode.
+	gateCtx, gateCancel := context.WithCancel(sessCtx)
+	if s.irq != nil && sess != nil {
+		s.sessionWg.Add(1)
+		go func() {
+			defer s.sessionWg.Done()
+			s.runIRQGate(gateCtx, sess)
+		}()
+	}
+
 	s.sessionWg.Add(1)
 	go func() {
 		defer s.sessionWg.Done()
-		if err := sess.Start(s.cancelCtx); err != nil && s.cancelCtx.Err() == nil {
+		defer gateCancel()
+		err := poller.Start(sessCtx)
+		if s.cancelCtx.Err() != nil {
+			return
+		}
+		if sessCtx.Err() != nil {
+			err = context.Cause(sessCtx)
+		} else if err != nil {
 			s.logger.Errorw("polling session exited with error", "error", err)
 		}
+		s.signalSessionExited(err)
 	}()
 }
 
+// signalSessionExited wakes the reconnect supervisor with the reason the
+// polling session stopped.
+func (s *pn532Sensor) signalSessionExited(err error) {
+	select {
+	case s.sessionExited <- err:
+	default:
+	}
+}
+
+// config returns the current config. Reconfigure replaces it, so code that
+// runs outside Reconfigure reads it through here.
+func (s *pn532Sensor) config() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
 func (s *pn532Sensor) onCardDetected(ctx context.Context, detectedTag *pn532.DetectedTag) error {
 	// I/O phase — no lock held. tagops calls go through the Device which
 	// the polling session has exclusive access to during this callback.
-	var ndefText string
-	var ndefRecordCount int
-	var ntagVariant, mifareVariant string
-	var userMemoryBytes int
-
-	ops := tagops.New(s.device)
-	if err := ops.InitFromDetectedTag(ctx, detectedTag); err != nil {
-		s.logger.Warnw("failed to initialize tag operations", "uid", detectedTag.UID, "error", err)
-	} else {
-		if info, err := ops.GetTagInfo(); err == nil {
-			ntagVariant = info.NTAGType
-			mifareVariant = info.MIFAREType
-			userMemoryBytes = info.UserMemory
-		}
-
-		if s.cfg.ReadNDEF != nil && *s.cfg.ReadNDEF {
-			if ndefMsg, err := ops.ReadNDEF(ctx); err != nil {
-				s.logger.Warnw("failed to read NDEF", "uid", detectedTag.UID, "error", err)
-			} else if ndefMsg != nil {
-				ndefRecordCount = len(ndefMsg.Records)
-				for _, r := range ndefMsg.Records {
-					if r.Type == pn532.NDEFTypeText && r.Text != "" {
-						ndefText = r.Text
-						break
-					}
-				}
-			}
+	s.mu.RLock()
+	device := s.device
+	s.mu.RUnlock()
+
+	s.authenticateConfigured(ctx, device, detectedTag, false, 0)
+
+	var ops *tagops.TagOperations
+	if s.config().PollMode != pollModeAutopoll || hasTagOps(detectedTag.Type) {
+		ops = tagops.New(device)
+		if err := ops.InitFromDetectedTag(ctx, detectedTag); err != nil {
+			s.logger.Warnw("failed to initialize tag operations", "uid", detectedTag.UID, "error", err)
+			ops = nil
 		}
 	}
+	info := s.readTagInfo(ctx, ops, detectedTag)
+	s.readPasswordState(ctx, device, ops, &info)
+	s.readSignature(ctx, device, detectedTag, &info)
+	s.readSDM(ctx, device, detectedTag, &info)
+	if detectedTag.Type == pn532.TagTypeFeliCa {
+		s.readFeliCaInfo(ctx, device, detectedTag, &info)
+	}
 
 	// Cache phase — write results under lock.
 	s.mu.Lock()
@@ -166,28 +315,63 @@ func (s *pn532Sensor) onCardDetected(ctx context.Context, detectedTag *pn532.Det
 		return nil
 	}
 
-	s.state.tagPresent = true
-	s.state.uid = detectedTag.UID
-	s.state.tagType = string(detectedTag.Type)
-	s.state.manufacturer = string(detectedTag.Manufacturer())
-	s.state.isGenuine = detectedTag.IsGenuine()
-	s.state.ndefText = ndefText
-	s.state.ndefRecordCount = ndefRecordCount
-	s.state.ntagVariant = ntagVariant
-	s.state.mifareVariant = mifareVariant
-	s.state.userMemoryBytes = userMemoryBytes
+	s.state.setTag(info)
 
-	// Deliver snapshot to any await_scan waiter. Drain first so rapid
-	// re-detections always deliver the freshest state.
-	select {
-	case <-s.scanNotify:
-	default:
+	// The snapshot travels with the event so every await_scan waiter sees the
+	// tag as detected, even if it has been removed by the time it reads it.
+	snapshot := s.state
+	s.events.add(tagEvent{kind: eventDetected, uid: info.uid, tagType: info.tagType, snapshot: &snapshot})
+
+	if info.accessGranted && s.relay != nil {
+		s.relay.trigger(s.cancelCtx)
 	}
-	s.scanNotify <- s.state
 
 	return nil
 }
 
+// readTagInfo builds the tag fields of a tagState for detectedTag. ops may be
+// nil if tag operations failed to initialize, in which case only the fields
+// available from the detection itself are populated. No lock is held; the
+// caller must have exclusive device access.
+func (s *pn532Sensor) readTagInfo(ctx context.Context, ops *tagops.TagOperations, detectedTag *pn532.DetectedTag) tagState {
+	info := tagState{
+		tagPresent:    true,
+		detectedAt:    detectedTag.DetectedAt,
+		uid:           detectedTag.UID,
+		tagType:       string(detectedTag.Type),
+		manufacturer:  string(detectedTag.Manufacturer()),
+		isGenuine:     detectedTag.IsGenuine(),
+		genuineMethod: genuineMethodManufacturer,
+	}
+	s.mu.RLock()
+	access, cfg := s.access, s.cfg
+	s.mu.RUnlock()
+	if access != nil {
+		info.accessGranted, info.accessReason = access.evaluate(info.uid)
+	}
+	if ops == nil {
+		return info
+	}
+
+	if tagInfo, err := ops.GetTagInfo(); err == nil {
+		info.ntagVariant = tagInfo.NTAGType
+		info.mifareVariant = tagInfo.MIFAREType
+		info.userMemoryBytes = tagInfo.UserMemory
+	}
+
+	if cfg.ReadNDEF != nil && *cfg.ReadNDEF {
+		if records, err := readNDEFRecords(ctx, ops); err != nil {
+			s.logger.Warnw("failed to read NDEF", "uid", detectedTag.UID, "error", err)
+		} else {
+			info.ndefRecords = records
+			info.ndefRecordCount = len(records)
+			info.ndefText = firstNDEFText(records)
+		}
+	}
+
+	return info
+}
+
 func (s *pn532Sensor) onCardRemoved() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -195,35 +379,34 @@ func (s *pn532Sensor) onCardRemoved() {
 		return
 	}
 
-	s.state.tagPresent = false
-	s.state.uid = ""
-	s.state.tagType = ""
-	s.state.manufacturer = ""
-	s.state.isGenuine = false
-	s.state.ndefText = ""
-	s.state.ndefRecordCount = 0
-	s.state.ntagVariant = ""
-	s.state.mifareVariant = ""
-	s.state.userMemoryBytes = 0
+	if s.state.tagPresent {
+		s.events.add(tagEvent{
+			kind:    eventRemoved,
+			uid:     s.state.uid,
+			tagType: s.state.tagType,
+			dwell:   time.Since(s.state.detectedAt),
+		})
+	}
+	s.state.clearTag()
 }
 
+// onDeviceDisconnected marks the device unhealthy. The polling session exits
+// after reporting a fatal error, which hands control to the reconnect
+// supervisor (see superviseConnection).
 func (s *pn532Sensor) onDeviceDisconnected(err error) {
 	s.logger.Errorw("PN532 device disconnected", "error", err)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.state.deviceHealthy {
+		s.events.add(tagEvent{kind: eventDeviceDisconnected, err: errorString(err)})
+	}
 	s.state.deviceHealthy = false
-	s.state.tagPresent = false
-	s.state.uid = ""
-	s.state.tagType = ""
-	s.state.manufacturer = ""
-	s.state.isGenuine = false
-	s.state.ndefText = ""
-	s.state.ndefRecordCount = 0
-	s.state.ntagVariant = ""
-	s.state.mifareVariant = ""
-	s.state.userMemoryBytes = 0
+	if err != nil {
+		s.state.lastDisconnectError = err.Error()
+	}
+	s.state.clearTag()
 }
 
 func (s *pn532Sensor) Name() resource.Name {
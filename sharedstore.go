@@ -0,0 +1,40 @@
+package pn532
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// storeCache hands out one store per file, so sensors in this process that
+// persist to the same file share its contents instead of each overwriting
+// the file with its own copy. A store without a file is never shared.
+type storeCache[T any] struct {
+	mu     sync.Mutex
+	stores map[string]*T
+}
+
+// open returns the store for path, loading it with load the first time.
+func (c *storeCache[T]) open(path string, load func(path string) (*T, error)) (*T, error) {
+	if path == "" {
+		return load(path)
+	}
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = filepath.Clean(path)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if st, ok := c.stores[key]; ok {
+		return st, nil
+	}
+	st, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if c.stores == nil {
+		c.stores = make(map[string]*T)
+	}
+	c.stores[key] = st
+	return st, nil
+}
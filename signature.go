@@ -0,0 +1,344 @@
+package pn532
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	pn532 "github.com/ZaparooProject/go-pn532"
+)
+
+// Originality signature commands. NTAG21x and Ultralight EV1 answer READ_SIG
+// with a 32-byte secp128r1 signature of their UID; NTAG 424 DNA answers the
+// ISO 7816-wrapped Read_Sig with a 56-byte secp224r1 signature.
+const (
+	ntagCmdReadSig   = 0x3C
+	ntagSignatureLen = 32
+
+	ntag424SignatureLen = 56
+
+	// desfireCmdGetVersion and desfireCmdAdditionalFrame are native
+	// commands in ISO 7816 wrapping; GetVersion answers in three frames.
+	desfireCmdGetVersion      = 0x60
+	desfireCmdAdditionalFrame = 0xAF
+)
+
+// genuine_method values: how is_genuine was decided. genuineMethodUnavailable
+// means the tag should have answered with a signature but did not, so
+// is_genuine is false rather than a manufacturer-byte guess.
+const (
+	genuineMethodSignature    = "nxp_signature"
+	genuineMethodManufacturer = "uid_manufacturer"
+	genuineMethodUnavailable  = "unavailable"
+)
+
+// ecPoint is an affine point; a nil x is the point at infinity.
+type ecPoint struct {
+	x, y *big.Int
+}
+
+// shortCurve is a short Weierstrass curve y² = x³ + ax + b over GF(p) with a
+// base point g of order n. crypto/elliptic does not provide secp128r1.
+type shortCurve struct {
+	p, a, b, n *big.Int
+	g          ecPoint
+}
+
+func hexInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid hex constant " + s)
+	}
+	return n
+}
+
+// secp128r1 domain parameters from SEC 2.
+var secp128r1 = &shortCurve{
+	p: hexInt("FFFFFFFDFFFFFFFFFFFFFFFFFFFFFFFF"),
+	a: hexInt("FFFFFFFDFFFFFFFFFFFFFFFFFFFFFFFC"),
+	b: hexInt("E87579C11079F43DD824993C2CEE5ED3"),
+	n: hexInt("FFFFFFFE0000000075A30D1B9038A115"),
+	g: ecPoint{
+		x: hexInt("161FF7528B899B2D0C28607CA52C5B86"),
+		y: hexInt("CF5AC8395BAFEB13C02DA292DDED7A83"),
+	},
+}
+
+func (c *shortCurve) onCurve(pt ecPoint) bool {
+	if pt.x == nil {
+		return false
+	}
+	lhs := new(big.Int).Mul(pt.y, pt.y)
+	lhs.Mod(lhs, c.p)
+	rhs := new(big.Int).Exp(pt.x, big.NewInt(3), c.p)
+	rhs.Add(rhs, new(big.Int).Mul(c.a, pt.x))
+	rhs.Add(rhs, c.b)
+	rhs.Mod(rhs, c.p)
+	return lhs.Cmp(rhs) == 0
+}
+
+func (c *shortCurve) add(p1, p2 ecPoint) ecPoint {
+	switch {
+	case p1.x == nil:
+		return p2
+	case p2.x == nil:
+		return p1
+	}
+	var lambda *big.Int
+	if p1.x.Cmp(p2.x) == 0 {
+		sum := new(big.Int).Add(p1.y, p2.y)
+		if sum.Mod(sum, c.p).Sign() == 0 {
+			return ecPoint{}
+		}
+		// Doubling: (3x² + a) / 2y.
+		num := new(big.Int).Mul(p1.x, p1.x)
+		num.Mul(num, big.NewInt(3))
+		num.Add(num, c.a)
+		den := new(big.Int).Lsh(p1.y, 1)
+		lambda = c.div(num, den)
+	} else {
+		num := new(big.Int).Sub(p2.y, p1.y)
+		den := new(big.Int).Sub(p2.x, p1.x)
+		lambda = c.div(num, den)
+	}
+
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, p1.x)
+	x.Sub(x, p2.x)
+	x.Mod(x, c.p)
+	y := new(big.Int).Sub(p1.x, x)
+	y.Mul(y, lambda)
+	y.Sub(y, p1.y)
+	y.Mod(y, c.p)
+	return ecPoint{x: x, y: y}
+}
+
+// div returns num / den mod p; den must be non-zero mod p.
+func (c *shortCurve) div(num, den *big.Int) *big.Int {
+	inv := new(big.Int).Mod(den, c.p)
+	inv.ModInverse(inv, c.p)
+	q := new(big.Int).Mul(num, inv)
+	return q.Mod(q, c.p)
+}
+
+func (c *shortCurve) scalarMult(pt ecPoint, k *big.Int) ecPoint {
+	var r ecPoint
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r = c.add(r, r)
+		if k.Bit(i) == 1 {
+			r = c.add(r, pt)
+		}
+	}
+	return r
+}
+
+// parsePoint decodes an uncompressed SEC 1 point (04 || x || y).
+func (c *shortCurve) parsePoint(data []byte) (ecPoint, error) {
+	size := (c.p.BitLen() + 7) / 8
+	if len(data) != 1+2*size || data[0] != 0x04 {
+		return ecPoint{}, fmt.Errorf("invalid uncompressed point of %d bytes", len(data))
+	}
+	pt := ecPoint{
+		x: new(big.Int).SetBytes(data[1 : 1+size]),
+		y: new(big.Int).SetBytes(data[1+size:]),
+	}
+	if !c.onCurve(pt) {
+		return ecPoint{}, fmt.Errorf("point is not on the curve")
+	}
+	return pt, nil
+}
+
+// verify checks an ECDSA signature r || s of msg. NXP signs the raw UID
+// without hashing; a message shorter than the order is used as is.
+func (c *shortCurve) verify(pub ecPoint, msg, sig []byte) bool {
+	size := (c.n.BitLen() + 7) / 8
+	if len(sig) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(c.n) >= 0 || s.Cmp(c.n) >= 0 {
+		return false
+	}
+	e := new(big.Int).SetBytes(msg)
+	if excess := len(msg)*8 - c.n.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+
+	w := new(big.Int).ModInverse(s, c.n)
+	u1 := new(big.Int).Mul(e, w)
+	u1.Mod(u1, c.n)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, c.n)
+
+	pt := c.add(c.scalarMult(c.g, u1), c.scalarMult(pub, u2))
+	if pt.x == nil {
+		return false
+	}
+	return new(big.Int).Mod(pt.x, c.n).Cmp(r) == 0
+}
+
+// NXP originality public keys.
+var (
+	// ntagOriginalityKeys are tried in order for READ_SIG signatures.
+	ntagOriginalityKeys = []struct {
+		name string
+		key  string
+	}{
+		{"NTAG21x", "04494E1A386D3D3CFE3DC10E5DE68A499B1C202DB5B132393E89ED19FE5BE8BC61"},
+		{"Ultralight EV1", "0490933BDCD6E99B4E255E3DA55389A827564E11718E017292FAF23226A96614B8"},
+	}
+	ntag424OriginalityKey = "048A9B380AF2EE1B98DC417FECC263F8449C7625CECE82D9B916C992DA209D68422B81EC20B65A66B5102A61596AF3379200599316A00A1410"
+)
+
+// verifyNTAGSignature checks a READ_SIG signature of uid against every
+// NTAG21x and Ultralight EV1 key.
+func verifyNTAGSignature(uid, sig []byte) bool {
+	for _, k := range ntagOriginalityKeys {
+		raw, _ := hex.DecodeString(k.key)
+		pub, err := secp128r1.parsePoint(raw)
+		if err != nil {
+			continue
+		}
+		if secp128r1.verify(pub, uid, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyNTAG424Signature checks a Read_Sig signature of uid against the
+// NTAG 424 DNA key.
+func verifyNTAG424Signature(uid, sig []byte) bool {
+	raw, _ := hex.DecodeString(ntag424OriginalityKey)
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P224(), raw)
+	if err != nil || len(sig) != ntag424SignatureLen {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:ntag424SignatureLen/2])
+	s := new(big.Int).SetBytes(sig[ntag424SignatureLen/2:])
+	return ecdsa.Verify(pub, uid, r, s)
+}
+
+// isISODEP reports whether the target supports ISO-DEP, which the PN532
+// activates itself and then frames InDataExchange data for.
+func isISODEP(tag *pn532.DetectedTag) bool {
+	return tag.SAK&0x20 != 0
+}
+
+// sendAPDU sends an ISO 7816-4 APDU to an ISO-DEP target and returns the
+// response data and status word.
+func sendAPDU(ctx context.Context, device *pn532.Device, apdu []byte) (data []byte, sw uint16, err error) {
+	res, err := device.SendDataExchange(ctx, apdu)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(res) < 2 {
+		return nil, 0, fmt.Errorf("APDU response too short: %d bytes", len(res))
+	}
+	n := len(res) - 2
+	return res[:n], uint16(res[n])<<8 | uint16(res[n+1]), nil
+}
+
+// isNTAG424DNA reports whether the ISO-DEP tag in the field is an NTAG 424
+// DNA: hardware type NTAG (04) with 416 or 448 bytes of storage (11h, 13h).
+// The remaining GetVersion frames are read so the tag is left idle.
+func isNTAG424DNA(ctx context.Context, device *pn532.Device) (bool, error) {
+	res, sw, err := sendAPDU(ctx, device, []byte{0x90, desfireCmdGetVersion, 0x00, 0x00, 0x00})
+	if err != nil {
+		return false, fmt.Errorf("GetVersion failed: %w", err)
+	}
+	if sw != 0x91AF || len(res) < 7 {
+		return false, fmt.Errorf("GetVersion failed with status %04X", sw)
+	}
+	for i := 0; i < 2 && sw == 0x91AF; i++ {
+		if _, sw, err = sendAPDU(ctx, device, []byte{0x90, desfireCmdAdditionalFrame, 0x00, 0x00, 0x00}); err != nil {
+			return false, fmt.Errorf("GetVersion failed: %w", err)
+		}
+	}
+	return res[0] == 0x04 && res[1] == 0x04 && (res[5] == 0x11 || res[5] == 0x13), nil
+}
+
+// ntagSupportsReadSig reports whether the Type 2 tag in the field identifies
+// itself with GET_VERSION as an NXP NTAG21x or Ultralight EV1, the models
+// that implement READ_SIG. NTAG203 and the original Ultralight do not answer
+// GET_VERSION.
+func ntagSupportsReadSig(ctx context.Context, device *pn532.Device) bool {
+	res, err := device.SendRawCommand(ctx, []byte{ntagCmdGetVersion})
+	if err != nil {
+		_ = reselectTag(ctx, device)
+		return false
+	}
+	return len(res) >= 8 && res[1] == 0x04 && (res[2] == 0x03 || res[2] == 0x04)
+}
+
+// readOriginalitySignature reads the originality signature of an NTAG21x,
+// Ultralight EV1 or NTAG 424 DNA and reports whether it verifies. Tags that
+// have no signature to check, including ISO-DEP cards other than NTAG 424 DNA,
+// return a nil signature and no error; an error means a tag that implements
+// the signature command did not answer it.
+func readOriginalitySignature(ctx context.Context, device *pn532.Device, tag *pn532.DetectedTag) (sig []byte, valid bool, err error) {
+	switch {
+	case tag.Type == pn532.TagTypeNTAG:
+		if !ntagSupportsReadSig(ctx, device) {
+			return nil, false, nil
+		}
+		res, err := device.SendDataExchange(ctx, []byte{ntagCmdReadSig, 0x00})
+		if err != nil {
+			_ = reselectTag(ctx, device)
+			return nil, false, fmt.Errorf("READ_SIG failed: %w", err)
+		}
+		if len(res) < ntagSignatureLen {
+			return nil, false, fmt.Errorf("READ_SIG returned %d bytes, want %d", len(res), ntagSignatureLen)
+		}
+		sig = res[:ntagSignatureLen]
+		return sig, verifyNTAGSignature(tag.UIDBytes, sig), nil
+
+	case isISODEP(tag) && len(tag.UIDBytes) == 7:
+		if ok, err := isNTAG424DNA(ctx, device); err != nil || !ok {
+			return nil, false, nil
+		}
+		// Native Read_Sig (address 00h) in ISO 7816 wrapping.
+		res, sw, err := sendAPDU(ctx, device, []byte{0x90, ntagCmdReadSig, 0x00, 0x00, 0x01, 0x00, 0x00})
+		if err != nil {
+			return nil, false, fmt.Errorf("Read_Sig failed: %w", err)
+		}
+		if sw != 0x9190 && sw != 0x9100 {
+			return nil, false, fmt.Errorf("Read_Sig failed with status %04X", sw)
+		}
+		if len(res) != ntag424SignatureLen {
+			return nil, false, fmt.Errorf("Read_Sig returned %d bytes, want %d", len(res), ntag424SignatureLen)
+		}
+		return res, verifyNTAG424Signature(tag.UIDBytes, res), nil
+	}
+	return nil, false, nil
+}
+
+// readSignature fills the originality signature fields of info. For a tag
+// that has an originality signature, it decides is_genuine in place of the
+// UID manufacturer byte; a signature the tag should have but refuses leaves
+// is_genuine false with genuine_method "unavailable", since clones typically
+// refuse READ_SIG. Other tags keep the manufacturer-byte result.
+func (s *pn532Sensor) readSignature(ctx context.Context, device *pn532.Device, detectedTag *pn532.DetectedTag, info *tagState) {
+	sig, valid, err := readOriginalitySignature(ctx, device, detectedTag)
+	if err != nil {
+		s.logger.Debugw("failed to read originality signature", "uid", detectedTag.UID, "error", err)
+		info.isGenuine = false
+		info.signatureValid = false
+		info.genuineMethod = genuineMethodUnavailable
+		return
+	}
+	if sig == nil {
+		return
+	}
+	info.signatureHex = hex.EncodeToString(sig)
+	info.signatureValid = valid
+	info.isGenuine = valid
+	info.genuineMethod = genuineMethodSignature
+	if !valid {
+		s.logger.Warnw("originality signature does not verify", "uid", detectedTag.UID, "signature", info.signatureHex)
+	}
+}
@@ -0,0 +1,209 @@
+package pn532
+
+import (
+	"context"
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	pn532lib "github.com/ZaparooProject/go-pn532"
+)
+
+func TestSecp128r1Parameters(t *testing.T) {
+	if !secp128r1.onCurve(secp128r1.g) {
+		t.Fatal("base point is not on the curve")
+	}
+	if pt := secp128r1.scalarMult(secp128r1.g, secp128r1.n); pt.x != nil {
+		t.Errorf("n*G = (%x, %x), want the point at infinity", pt.x, pt.y)
+	}
+	for _, k := range ntagOriginalityKeys {
+		raw, _ := hex.DecodeString(k.key)
+		if _, err := secp128r1.parsePoint(raw); err != nil {
+			t.Errorf("%s key: %v", k.name, err)
+		}
+	}
+}
+
+// TestShortCurveMatchesP256 checks the point arithmetic against
+// crypto/elliptic on a curve it implements.
+func TestShortCurveMatchesP256(t *testing.T) {
+	params := elliptic.P256().Params()
+	curve := &shortCurve{
+		p: params.P,
+		a: new(big.Int).Sub(params.P, big.NewInt(3)),
+		b: params.B,
+		n: params.N,
+		g: ecPoint{x: params.Gx, y: params.Gy},
+	}
+	for _, k := range []string{"1", "2", "3", "deadbeef", "ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632550"} {
+		scalar := hexInt(k)
+		got := curve.scalarMult(curve.g, scalar)
+		wantX, wantY := params.ScalarBaseMult(scalar.Bytes())
+		if got.x.Cmp(wantX) != 0 || got.y.Cmp(wantY) != 0 {
+			t.Errorf("%s*G = (%x, %x), want (%x, %x)", k, got.x, got.y, wantX, wantY)
+		}
+	}
+}
+
+// signSecp128r1 makes an unhashed ECDSA signature r || s of msg.
+func signSecp128r1(d, k *big.Int, msg []byte) []byte {
+	c := secp128r1
+	r := new(big.Int).Mod(c.scalarMult(c.g, k).x, c.n)
+	s := new(big.Int).Mul(r, d)
+	s.Add(s, new(big.Int).SetBytes(msg))
+	s.Mul(s, new(big.Int).ModInverse(k, c.n))
+	s.Mod(s, c.n)
+	sig := make([]byte, 32)
+	r.FillBytes(sig[:16])
+	s.FillBytes(sig[16:])
+	return sig
+}
+
+func TestSecp128r1Verify(t *testing.T) {
+	d := hexInt("0123456789abcdef0123456789abcdef")
+	pub := secp128r1.scalarMult(secp128r1.g, d)
+	uid := []byte{0x04, 0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC}
+	sig := signSecp128r1(d, hexInt("fedcba9876543210fedcba9876543210"), uid)
+
+	if !secp128r1.verify(pub, uid, sig) {
+		t.Fatal("valid signature does not verify")
+	}
+	other := []byte{0x04, 0x12, 0x34, 0x56, 0x78, 0x9A, 0xBD}
+	if secp128r1.verify(pub, other, sig) {
+		t.Error("signature verifies for a different UID")
+	}
+	sig[31] ^= 0x01
+	if secp128r1.verify(pub, uid, sig) {
+		t.Error("altered signature verifies")
+	}
+	if verifyNTAGSignature(uid, sig) {
+		t.Error("signature from a non-NXP key verifies against the NXP keys")
+	}
+}
+
+func TestOnCardDetectedSignature(t *testing.T) {
+	readNDEF := false
+	s, mock := newTestSensorWithDevice(t, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+	})
+	tag := setupNTAG215Mock(mock)
+	mock.QueueResponse(0x42, ntag215Version)
+	mock.QueueResponse(0x40, ntagConfigResponse(ntagAuth0Disabled, 0x00))
+	sig := make([]byte, ntagSignatureLen)
+	for i := range sig {
+		sig[i] = byte(i)
+	}
+	mock.QueueResponse(0x40, append([]byte{0x41, 0x00}, sig...))
+
+	if err := s.onCardDetected(context.Background(), tag); err != nil {
+		t.Fatalf("onCardDetected returned error: %v", err)
+	}
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	if readings["signature_hex"] != hex.EncodeToString(sig) {
+		t.Errorf("signature_hex = %v, want %x", readings["signature_hex"], sig)
+	}
+	if readings["signature_valid"] != false || readings["is_genuine"] != false {
+		t.Errorf("signature_valid, is_genuine = %v, %v, want false, false", readings["signature_valid"], readings["is_genuine"])
+	}
+	if readings["genuine_method"] != genuineMethodSignature {
+		t.Errorf("genuine_method = %v, want %s", readings["genuine_method"], genuineMethodSignature)
+	}
+}
+
+func TestOnCardDetectedSignatureUnavailable(t *testing.T) {
+	readNDEF := false
+	s, mock := newTestSensorWithDevice(t, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+	})
+	tag := setupNTAG215Mock(mock)
+	mock.QueueResponse(0x42, ntag215Version)
+
+	if err := s.onCardDetected(context.Background(), tag); err != nil {
+		t.Fatalf("onCardDetected returned error: %v", err)
+	}
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	if _, ok := readings["signature_hex"]; ok {
+		t.Errorf("signature_hex = %v, want omitted", readings["signature_hex"])
+	}
+	// An NTAG215 that refuses READ_SIG is not trusted on its manufacturer
+	// byte.
+	if readings["genuine_method"] != genuineMethodUnavailable || readings["is_genuine"] != false || readings["signature_valid"] != false {
+		t.Errorf("genuine_method, is_genuine, signature_valid = %v, %v, %v",
+			readings["genuine_method"], readings["is_genuine"], readings["signature_valid"])
+	}
+}
+
+func TestOnCardDetectedSignatureNotSupported(t *testing.T) {
+	readNDEF := false
+	s, mock := newTestSensorWithDevice(t, &Config{
+		Transport:  "i2c",
+		DevicePath: "/dev/i2c-1",
+		ReadNDEF:   &readNDEF,
+	})
+	tag := setupNTAG215Mock(mock)
+	// An NTAG203 does not answer GET_VERSION and has no READ_SIG.
+	mock.SetError(0x42, errors.New("no GET_VERSION"))
+
+	if err := s.onCardDetected(context.Background(), tag); err != nil {
+		t.Fatalf("onCardDetected returned error: %v", err)
+	}
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	if readings["genuine_method"] != genuineMethodManufacturer || readings["is_genuine"] != true {
+		t.Errorf("genuine_method, is_genuine = %v, %v, want %s, true",
+			readings["genuine_method"], readings["is_genuine"], genuineMethodManufacturer)
+	}
+	if _, ok := readings["signature_valid"]; ok {
+		t.Errorf("signature_valid = %v, want omitted", readings["signature_valid"])
+	}
+}
+
+// queueISODEPVersion queues the three GetVersion frames of an ISO-DEP tag
+// with the given hardware type and storage size.
+func queueISODEPVersion(mock *pn532lib.MockTransport, hwType, storage byte) {
+	mock.QueueResponse(0x40, apduResponse(0x91AF, 0x04, hwType, 0x02, 0x01, 0x00, storage, 0x05))
+	mock.QueueResponse(0x40, apduResponse(0x91AF, 0x04, hwType, 0x02, 0x01, 0x00, storage, 0x05))
+	mock.QueueResponse(0x40, apduResponse(0x9100, 0x04, 0xDE, 0x5F, 0x1E, 0xAC, 0xC0, 0x40, 0xBA, 0xDC, 0xD6, 0x00, 0x00, 0x00, 0x00))
+}
+
+func TestOnCardDetectedDESFireSignature(t *testing.T) {
+	readNDEF := false
+	s, mock := newTestSensorWithDevice(t, &Config{
+		Transport:  "uart",
+		DevicePath: "/dev/ttyUSB0",
+		ReadNDEF:   &readNDEF,
+		PollMode:   pollModeAutopoll,
+	})
+	mock.SelectTarget()
+	// A DESFire EV2: hardware type 01. Read_Sig must not be sent.
+	queueISODEPVersion(mock, 0x01, 0x18)
+
+	if err := s.onCardDetected(context.Background(), sdmDetectedTag()); err != nil {
+		t.Fatalf("onCardDetected returned error: %v", err)
+	}
+	if got := mock.GetCallCount(0x40); got != 3 {
+		t.Errorf("InDataExchange calls = %d, want 3 (GetVersion only)", got)
+	}
+	readings, err := s.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Readings returned error: %v", err)
+	}
+	// A DESFire has no signature to check, so the manufacturer byte decides.
+	if readings["genuine_method"] != genuineMethodManufacturer || readings["is_genuine"] != true {
+		t.Errorf("genuine_method, is_genuine = %v, %v", readings["genuine_method"], readings["is_genuine"])
+	}
+}
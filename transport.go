@@ -3,6 +3,7 @@ package pn532
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	pn532 "github.com/ZaparooProject/go-pn532"
@@ -27,17 +28,46 @@ func transportFactory(transportType string) pn532.TransportFactory {
 	}
 }
 
-func connectDevice(ctx context.Context, cfg *Config, logger logging.Logger) (*pn532.Device, error) {
-	timeout := time.Duration(cfg.ConnectTimeoutSec) * time.Second
+// deviceTimeout is the connect timeout, which ConnectDevice also leaves on the
+// device as its operation timeout.
+func deviceTimeout(cfg *Config) time.Duration {
+	return time.Duration(cfg.ConnectTimeoutSec) * time.Second
+}
+
+// connectDevice opens the PN532 at cfg's transport and device path, resolving
+// a glob pattern or usb: selector in the path and probing for the transport
+// if it is "auto". It returns the transport and path it connected with.
+func connectDevice(ctx context.Context, cfg *Config, logger logging.Logger) (*pn532.Device, transportCandidate, error) {
+	path, err := defaultDevicePathResolver.resolve(cfg.DevicePath)
+	if err != nil {
+		return nil, transportCandidate{}, err
+	}
+	if path != cfg.DevicePath {
+		logger.Debugw("resolved device_path", "device_path", cfg.DevicePath, "device", path)
+	}
+
+	endpoint := transportCandidate{cfg.Transport, path}
+	if cfg.Transport == transportAuto {
+		candidates := autoDetectCandidates(path, filepath.Glob)
+		if isUSBSelector(cfg.DevicePath) {
+			candidates = []transportCandidate{{"uart", path}}
+		}
+		if endpoint, err = detectTransport(ctx, candidates, probePN532, logger); err != nil {
+			return nil, endpoint, err
+		}
+	}
+
+	timeout := deviceTimeout(cfg)
 
-	logger.Infof("Connecting to PN532 via %s at %s (timeout %s)", cfg.Transport, cfg.DevicePath, timeout)
-	return pn532.ConnectDevice(ctx, cfg.DevicePath,
+	logger.Infof("Connecting to PN532 via %s at %s (timeout %s)", endpoint.transport, endpoint.path, timeout)
+	device, err := pn532.ConnectDevice(ctx, endpoint.path,
 		pn532.WithConnectTimeout(timeout),
-		pn532.WithTransportFactory(transportFactory(cfg.Transport)),
+		pn532.WithTransportFactory(transportFactory(endpoint.transport)),
 		// Use more retries to survive reconnection after a kill. The PN532 may
 		// be busy finishing a stale InListPassiveTarget command (up to ~5s
 		// hardware timeout) and will NAK I2C addresses until it's done. 10
 		// retries with exponential backoff spans the full connect timeout window.
 		pn532.WithConnectionRetries(10),
 	)
+	return device, endpoint, err
 }